@@ -16,6 +16,11 @@ func main() {
 	jsonPtr := flag.StringP("json", "j", "", "JSON text")
 	flag.VarP(&pathStrings, "path", "p", "One or more paths to target in JSON")
 	showKeysPtr := flag.BoolP("keys", "k", false, "Print keys & indexes that lead to value")
+	showKindPtr := flag.BoolP("show-kind", "T", false, "Print the kind of each value")
+	kindsPtr := flag.StringP("kind", "t", "", "Only print values of these comma separated kinds, e.g. string,number")
+	exactPtr := flag.BoolP("exact", "x", false, "Compare and compute numbers in filters exactly")
+	strictPtr := flag.BoolP("strict", "s", false, "Reject JSON that is not valid RFC 8259")
+	lenientPtr := flag.BoolP("lenient", "l", false, "Accept comments, trailing commas, single quotes, unquoted keys and hex numbers")
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
 		flag.PrintDefaults()
@@ -28,11 +33,28 @@ func main() {
 		os.Exit(1)
 	}
 
+	kinds, err := parseKinds(*kindsPtr)
+	checkAndHandleError(err)
+	out := output{showKeys: *showKeysPtr, showKind: *showKindPtr, kinds: kinds}
+	opts := jsonpath.Options{Strict: *strictPtr}
+	if *lenientPtr {
+		opts.Comments, opts.TrailingCommas, opts.SingleQuotes = true, true, true
+		opts.UnquotedKeys, opts.HexNumbers = true, true
+	}
+
 	paths, err := jsonpath.ParsePaths(pathStrings...)
 	if err != nil {
 		fmt.Println(fmt.Errorf("Failed to parse paths: %q", err.Error()))
 		os.Exit(1)
 	}
+	if *exactPtr {
+		for _, p := range paths {
+			if err := p.ExactNumbers(); err != nil {
+				fmt.Println(fmt.Errorf("Failed to parse paths: %q", err.Error()))
+				os.Exit(1)
+			}
+		}
+	}
 
 	if filePtr != nil && *filePtr != "" {
 		f, err := os.Open(*filePtr)
@@ -41,31 +63,40 @@ func main() {
 			os.Exit(1)
 		}
 
-		eval, err := jsonpath.EvalPathsInReader(f, paths)
+		eval, err := jsonpath.EvalPathsInReader(f, paths, opts)
 		checkAndHandleError(err)
-		run(eval, *showKeysPtr)
+		run(eval, out)
 		checkAndHandleError(eval.Error)
 		f.Close()
 
 	} else if jsonPtr != nil && *jsonPtr != "" {
-		eval, err := jsonpath.EvalPathsInBytes([]byte(*jsonPtr), paths)
+		eval, err := jsonpath.EvalPathsInBytes([]byte(*jsonPtr), paths, opts)
 		checkAndHandleError(err)
-		run(eval, *showKeysPtr)
+		run(eval, out)
 		checkAndHandleError(eval.Error)
 	} else {
 		reader := bufio.NewReader(os.Stdin)
-		eval, err := jsonpath.EvalPathsInReader(reader, paths)
+		eval, err := jsonpath.EvalPathsInReader(reader, paths, opts)
 		checkAndHandleError(err)
-		run(eval, *showKeysPtr)
+		run(eval, out)
 		checkAndHandleError(eval.Error)
 	}
 }
 
-func run(eval *jsonpath.Eval, showKeys bool) {
+type output struct {
+	showKeys bool
+	showKind bool
+	kinds    map[jsonpath.Kind]bool
+}
+
+func run(eval *jsonpath.Eval, out output) {
 	for {
 		result, running := eval.Next()
-		if result != nil {
-			fmt.Print(result.Pretty(showKeys))
+		if result != nil && (out.kinds == nil || out.kinds[result.Type]) {
+			if out.showKind {
+				fmt.Printf("%s\t", result.Type)
+			}
+			fmt.Print(result.Pretty(out.showKeys))
 		}
 		if !running {
 			break
@@ -73,6 +104,26 @@ func run(eval *jsonpath.Eval, showKeys bool) {
 	}
 }
 
+func parseKinds(value string) (map[jsonpath.Kind]bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+	kinds := make(map[jsonpath.Kind]bool)
+	for _, name := range strings.Split(value, ",") {
+		found := false
+		for k := jsonpath.Unknown; k <= jsonpath.JsonBool; k++ {
+			if k.String() == strings.TrimSpace(name) {
+				kinds[k] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("Unknown kind: %q", name)
+		}
+	}
+	return kinds, nil
+}
+
 func checkAndHandleError(err error) {
 	if err != nil {
 		fmt.Println(err)
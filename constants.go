@@ -6,9 +6,3 @@ const (
 	UnexpectedToken      = "Unexpected token in evaluation"
 	AbruptTokenStreamEnd = "Token reader is not sending anymore tokens"
 )
-
-var (
-	bytesTrue  = []byte{'t', 'r', 'u', 'e'}
-	bytesFalse = []byte{'f', 'a', 'l', 's', 'e'}
-	bytesNull  = []byte{'n', 'u', 'l', 'l'}
-)
@@ -2,6 +2,7 @@ package jsonpath
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 )
 
@@ -12,19 +13,36 @@ type query struct {
 	state       queryStateFn
 	start       int
 	pos         int
-	firstType   int // first json token type in buffer
+	firstType   int  // first json token type in buffer
+	first       Item // first token of the value, for its position
+	end         Pos  // position after the last token of the value
 	buffer      bytes.Buffer
 	resultQueue *Results
-	valLoc      stack // capture the current location stack at capture
-	errors      []error
-	buckets     stack // stack of exprBucket
+	valLoc      stack        // capture the current location stack at capture
+	failed      *FilterError // shared by all queries of the evaluation
+	buckets     stack        // stack of exprBucket
+	descendants []*query     // queries spawned by a descendant operator, in document order
+	running     []*query     // the descendants that have not finished
+	scope       int          // descendant queries end once location drops below scope
+	finished    bool
+	roots       map[string]*rootRef // values of the $ paths in filters, shared by all queries
+	env         filterEnv           // reused by each filter the query evaluates
 }
 
 type exprBucket struct {
 	operatorLoc int
 	expression  []Item
+	dialect     int
+	filter      *operator
+	unfiltered  bool // selected by another selector of the bracket
 	queries     []*query
 	results     *Results
+
+	// Negative indexes hold the results of each array element in a window
+	// until it is known whether the element is selected
+	window  *indexWindow
+	element bool
+	index   int
 }
 
 type evalStateFn func(*Eval, *Item) evalStateFn
@@ -38,9 +56,61 @@ type Eval struct {
 	prevIndex  int
 	nextKey    []byte
 	copyValues bool
+	newNode    bool // current token started a new value in location
 
 	resultQueue *Results
+	resultPaths map[*Result]string // path string of each result, if tracked
+	roots       map[string]*rootRef
+	rootOrder   []*rootRef
 	Error       error
+	failed      FilterError
+
+	// inputs of several documents evaluate the paths again for each one
+	paths     []*Path
+	documents bool
+	document  int
+	readAll   bool // keep reading once every path is done
+
+	ctx  context.Context // stops the evaluation between tokens, if set
+	done <-chan struct{}
+}
+
+// rootRef runs a $ path used in a filter over the whole document. Filters
+// that use it are held back until it is resolved, which is at the end of the
+// document unless the path selects at most one value and has found it.
+type rootRef struct {
+	query    *query
+	nodes    []Item
+	singular bool
+	resolved bool
+}
+
+// heldFilter is a filter held back in a result queue until the $ paths it
+// uses are resolved. It holds back the results after it as well, so results
+// keep their order.
+type heldFilter struct {
+	bucket    exprBucket
+	evaluated bool
+	selected  bool
+}
+
+// FilterError reports the nodes that a filter could not be evaluated on, for
+// example because a value has the wrong type for an operator. Such nodes are
+// not selected and the evaluation goes on.
+type FilterError struct {
+	Nodes int   // number of nodes the filters failed on
+	First error // error of the first of them
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("Filters failed on %d nodes, the first with: %s", e.Nodes, e.First)
+}
+
+func (e *FilterError) add(err error) {
+	if e.Nodes == 0 {
+		e.First = err
+	}
+	e.Nodes++
 }
 
 func newEvaluation(tr tokenReader, paths ...*Path) *Eval {
@@ -54,11 +124,10 @@ func newEvaluation(tr tokenReader, paths ...*Path) *Eval {
 		nextKey:     nil,
 		copyValues:  true, // depends on which lexer is used
 		resultQueue: newResults(),
+		paths:       paths,
 	}
+	e.addQueries()
 
-	for _, p := range paths {
-		e.queries[p.stringValue] = newQuery(p)
-	}
 	// Determine whether to copy emitted item values ([]byte) from lexer
 	switch tr.(type) {
 	case *readerLexer:
@@ -70,6 +139,52 @@ func newEvaluation(tr tokenReader, paths ...*Path) *Eval {
 	return e
 }
 
+// addQueries starts a query for each path
+func (e *Eval) addQueries() {
+	e.queries = make(map[string]*query, len(e.paths))
+	e.roots = make(map[string]*rootRef)
+	e.rootOrder = nil
+	for _, p := range e.paths {
+		q := newQuery(p)
+		q.roots = e.roots
+		q.failed = &e.failed
+		e.queries[p.stringValue] = q
+		e.addRootRefs(p)
+	}
+}
+
+// nextDocument resets the evaluation for the next document of the input
+func (e *Eval) nextDocument() {
+	e.document++
+	e.state = evalRoot
+	e.levelStack = *newIntStack()
+	e.location = *newStack()
+	e.prevIndex = -1
+	e.nextKey = nil
+	e.addQueries()
+}
+
+// addRootRefs starts a query for each $ path in the filters of p
+func (e *Eval) addRootRefs(p *Path) {
+	for _, op := range p.operators {
+		for _, dp := range op.dependentPaths {
+			if dp.isRoot() {
+				if _, ok := e.roots[dp.stringValue]; ok {
+					continue
+				}
+				q := newQuery(dp)
+				q.captureEndValue = true
+				q.roots = e.roots
+				q.failed = &e.failed
+				r := &rootRef{query: q, singular: dp.singular()}
+				e.roots[dp.stringValue] = r
+				e.rootOrder = append(e.rootOrder, r)
+			}
+			e.addRootRefs(dp)
+		}
+	}
+}
+
 func newQuery(p *Path) *query {
 	return &query{
 		Path:        *p,
@@ -77,60 +192,89 @@ func newQuery(p *Path) *query {
 		start:       -1,
 		pos:         -1,
 		buffer:      *bytes.NewBuffer(make([]byte, 0, 50)),
-		valLoc:      *newStack(),
-		errors:      make([]error, 0),
 		resultQueue: newResults(),
-		buckets:     *newStack(),
 	}
 }
 
 func (e *Eval) Iterate() (*Results, bool) {
 	e.resultQueue.clear()
 
+	if e.done != nil {
+		select {
+		case <-e.done:
+			e.Error = e.ctx.Err()
+			e.state = nil
+			return nil, false
+		default:
+		}
+	}
+
 	t, ok := e.tr.next()
 	if !ok || e.state == nil {
 		return nil, false
 	}
 
 	// run evaluator function
+	depth := e.location.len()
 	e.state = e.state(e, t)
+	e.newNode = e.location.len() > depth
+
+	// $ paths in filters go first, so filters see them resolved as early
+	// as possible
+	for _, r := range e.rootOrder {
+		r.iterate(e, t)
+	}
 
 	anyRunning := false
 	// run path function for each path
 	for str, query := range e.queries {
 		anyRunning = true
-		query.state = query.state(query, e, t)
-		if query.state == nil {
-			delete(e.queries, str)
+		if query.state != nil {
+			query.iterate(e, t)
 		}
 
-		if query.resultQueue.len() > 0 {
-			e.resultQueue.push(query.resultQueue.Pop())
-		}
-
-		for _, b := range query.buckets.values {
-			bucket := b.(exprBucket)
-			for _, dq := range bucket.queries {
-				dq.state = dq.state(dq, e, t)
-
-				if query.resultQueue.len() > 0 {
-					e.resultQueue.push(query.resultQueue.Pop())
-				}
+		query.releaseResults(query.resultQueue, func(r *Result) {
+			r.Document = e.document
+			if e.resultPaths != nil {
+				e.resultPaths[r] = query.stringValue
 			}
+			e.resultQueue.push(r)
+		})
+		// a finished query stays until its held back results are released
+		if query.state == nil && query.resultQueue.len() == 0 {
+			delete(e.queries, str)
 		}
 	}
 
-	if !anyRunning {
+	if e.Error != nil {
 		return nil, false
 	}
 
-	if e.Error != nil {
+	if e.documents {
+		// later documents need reading even when the paths are done
+		if t.typ == jsonEOF {
+			e.nextDocument()
+		}
+		return e.resultQueue, true
+	}
+
+	if !anyRunning && !e.readAll {
 		return nil, false
 	}
 
 	return e.resultQueue, true
 }
 
+// FilterError returns the nodes that filters failed on so far, or nil if
+// every filter could be evaluated
+func (e *Eval) FilterError() *FilterError {
+	if e.failed.Nodes == 0 {
+		return nil
+	}
+	failed := e.failed
+	return &failed
+}
+
 func (e *Eval) Next() (*Result, bool) {
 	if e.resultQueue.len() > 0 {
 		return e.resultQueue.Pop(), true
@@ -149,40 +293,215 @@ func (e *Eval) Next() (*Result, bool) {
 	return nil, false
 }
 
+func (r *rootRef) iterate(e *Eval, i *Item) {
+	if r.resolved {
+		return
+	}
+	if r.query.state != nil {
+		r.query.iterate(e, i)
+	}
+	released := r.query.releaseResults(r.query.resultQueue, func(res *Result) {
+		if t, err := getJsonTokenType(res.Value); err == nil {
+			r.nodes = append(r.nodes, Item{typ: t, val: res.Value})
+		}
+	})
+	if released && (i.typ == jsonEOF || r.query.state == nil || r.singular && len(r.nodes) > 0) {
+		r.resolved = true
+	}
+}
+
+// releaseResults passes the results of rs to out in order, evaluating held
+// filters whose $ paths are resolved. It stops at a filter that is still
+// held and reports whether rs was emptied.
+func (q *query) releaseResults(rs *Results, out func(*Result)) bool {
+	for rs.len() > 0 {
+		r := rs.peek()
+		if h := r.held; h != nil {
+			if !q.rootsResolved(h.bucket.filter) {
+				return false
+			}
+			if !h.evaluated {
+				selected, err := h.bucket.evaluate(&q.env, q.roots)
+				if err != nil {
+					q.failed.add(err)
+				}
+				h.evaluated = true
+				h.selected = selected
+			}
+			if h.selected && !q.releaseResults(h.bucket.results, out) {
+				return false
+			}
+		} else {
+			out(r)
+		}
+		rs.Pop()
+	}
+	return true
+}
+
+func (q *query) rootsResolved(op *operator) bool {
+	for _, p := range op.rootPaths {
+		if r, ok := q.roots[p]; !ok || !r.resolved {
+			return false
+		}
+	}
+	return true
+}
+
+func (q *query) iterate(e *Eval, i *Item) {
+	// The queries of a filter see each token before the filter can be
+	// evaluated, since a scalar @ ends on the token that leaves the node
+	for _, b := range q.buckets.values {
+		bucket := b.(exprBucket)
+		for _, dq := range bucket.queries {
+			dq.iterate(e, i)
+		}
+	}
+
+	// Descendant queries see the token first, so those that end with it
+	// release their results before the filter or window of the node they
+	// started in is decided
+	q.iterateDescendants(e, i)
+
+	q.state = q.state(q, e, i)
+}
+
+// iterateDescendants runs the queries spawned by a descendant operator.
+// Results are released in the order the queries were spawned, so a match
+// nested inside an earlier match waits until the earlier one is finished.
+func (q *query) iterateDescendants(e *Eval, i *Item) {
+	if len(q.descendants) == 0 {
+		return
+	}
+
+	// Only unfinished queries see the token, so a long running query does
+	// not keep the finished ones after it in the loop
+	curLocation := e.location.len() - 1
+	running := q.running[:0]
+	for _, dq := range q.running {
+		dq.iterate(e, i)
+		// Descendant queries end once their starting node is left, or with
+		// the document when they start at the root
+		if curLocation < dq.scope || i.typ == jsonEOF {
+			for dq.buckets.len() > 0 {
+				dq.spillOver()
+			}
+			dq.finished = true
+			continue
+		}
+		running = append(running, dq)
+	}
+	q.running = running
+
+	var spillover *Results
+	if b, ok := q.buckets.peek(); ok {
+		spillover = b.(exprBucket).results
+	} else {
+		spillover = q.resultQueue
+	}
+
+	for len(q.descendants) > 0 {
+		dq := q.descendants[0]
+		for dq.resultQueue.len() > 0 {
+			spillover.push(dq.resultQueue.Pop())
+		}
+		if !dq.finished {
+			break
+		}
+		q.descendants[0] = nil
+		q.descendants = q.descendants[1:]
+	}
+}
+
+func (q *query) matchDescendant(e *Eval, i *Item, op *operator) {
+	curLocation := e.location.len() - 1
+	if e.newNode && q.loc() < curLocation {
+		current, _ := e.location.peek()
+		if _, isIndex := current.(int); !(isIndex && op.windowed()) && itemMatchOperator(current, i, op) {
+			q.spawnDescendant(e, i, op, curLocation-1, curLocation)
+		}
+	}
+	if op.windowed() && i.typ == jsonBracketLeft && q.loc() <= curLocation {
+		// Selecting from the end of an array needs the whole array, so the
+		// query starts at every array instead of at every element
+		q.spawnDescendant(e, i, op, curLocation, curLocation)
+	}
+}
+
+// spawnDescendant starts a query for the rest of the path at the node of the
+// current token, which the query sees right away
+func (q *query) spawnDescendant(e *Eval, i *Item, op *operator, start, scope int) {
+	dq := newQuery(op.descendantPath)
+	dq.roots = q.roots
+	dq.failed = q.failed
+	dq.start = start
+	dq.pos = start
+	dq.scope = scope
+	dq.iterate(e, i)
+	q.descendants = append(q.descendants, dq)
+	q.running = append(q.running, dq)
+}
+
 func (q *query) loc() int {
 	return abs(q.pos-q.start) + q.start
 }
 
 func (q *query) trySpillOver() {
-	if b, ok := q.buckets.peek(); ok {
-		bucket := b.(exprBucket)
-		if q.loc() < bucket.operatorLoc {
-			q.buckets.pop()
+	for {
+		b, ok := q.buckets.peek()
+		if !ok || q.loc() >= b.(exprBucket).operatorLoc {
+			return
+		}
+		q.spillOver()
+	}
+}
 
-			exprRes, err := bucket.evaluate()
-			if err != nil {
-				q.errors = append(q.errors, err)
-			}
-			if exprRes {
-				next, ok := q.buckets.peek()
-				var spillover *Results
-				if !ok {
-					// fmt.Println("Spilling over into end queue")
-					spillover = q.resultQueue
-				} else {
-					// fmt.Println("Spilling over into lower bucket")
-					nextBucket := next.(exprBucket)
-					spillover = nextBucket.results
-				}
-				for {
-					v := bucket.results.Pop()
-					if v != nil {
-						spillover.push(v)
-					} else {
-						break
-					}
-				}
-			}
+// spillTarget returns the results of the nth bucket from the top, or the
+// result queue when there are not that many buckets.
+func (q *query) spillTarget(n int) *Results {
+	x := q.buckets.len() - 1 - n
+	if x < 0 {
+		return q.resultQueue
+	}
+	return q.buckets.values[x].(exprBucket).results
+}
+
+// spillOver pops the top bucket and moves its results into the bucket below
+func (q *query) spillOver() {
+	b, _ := q.buckets.pop()
+	bucket := b.(exprBucket)
+
+	switch {
+	case bucket.window != nil:
+		bucket.window.close(q.spillTarget(0))
+		moveResults(bucket.results, q.spillTarget(0))
+	case bucket.element:
+		w, _ := q.buckets.peek()
+		w.(exprBucket).window.add(bucket.index, bucket.results, q.spillTarget(1))
+	case bucket.unfiltered:
+		moveResults(bucket.results, q.spillTarget(0))
+	default:
+		if !q.rootsResolved(bucket.filter) {
+			q.spillTarget(0).push(&Result{held: &heldFilter{bucket: bucket}})
+			return
+		}
+		exprRes, err := bucket.evaluate(&q.env, q.roots)
+		if err != nil {
+			q.failed.add(err)
+		}
+		if exprRes {
+			moveResults(bucket.results, q.spillTarget(0))
+		}
+	}
+}
+
+func moveResults(from, to *Results) {
+	for {
+		v := from.Pop()
+		if v != nil {
+			to.push(v)
+		} else {
+			break
 		}
 	}
 }
@@ -190,10 +509,16 @@ func (q *query) trySpillOver() {
 func pathMatchOp(q *query, e *Eval, i *Item) queryStateFn {
 	curLocation := e.location.len() - 1
 
+	if i.typ == jsonEOF {
+		for q.buckets.len() > 0 {
+			q.spillOver()
+		}
+	}
+
 	if q.loc() > curLocation {
 		q.pos -= 1
 		q.trySpillOver()
-	} else if q.loc() <= curLocation {
+	} else if q.loc() <= curLocation && !q.nextIsDescendant() {
 		if q.loc() == curLocation-1 {
 			if len(q.operators)+q.start >= curLocation {
 				current, _ := e.location.peek()
@@ -201,19 +526,32 @@ func pathMatchOp(q *query, e *Eval, i *Item) queryStateFn {
 				if itemMatchOperator(current, i, nextOp) {
 					q.pos += 1
 
+					if index, isIndex := current.(int); isIndex && nextOp.windowed() {
+						q.pushElement(index)
+					}
+
 					if nextOp.whereClauseBytes != nil && len(nextOp.whereClause) > 0 {
 						bucket := exprBucket{
 							operatorLoc: q.loc(),
 							expression:  nextOp.whereClause,
-							queries:     make([]*query, len(nextOp.dependentPaths)),
+							dialect:     q.dialect,
+							filter:      nextOp,
+							unfiltered:  nextOp.unfiltered != nil && itemMatchOperator(current, i, nextOp.unfiltered),
 							results:     newResults(),
 						}
 
-						for i, p := range nextOp.dependentPaths {
-							bucket.queries[i] = newQuery(p)
-							bucket.queries[i].pos = q.loc()
-							bucket.queries[i].start = q.loc()
-							bucket.queries[i].captureEndValue = true
+						for _, p := range nextOp.dependentPaths {
+							if p.isRoot() {
+								continue
+							}
+							dq := newQuery(p)
+							dq.pos = q.loc()
+							dq.start = q.loc()
+							dq.captureEndValue = true
+							dq.roots = q.roots
+							dq.failed = q.failed
+							dq.iterate(e, i)
+							bucket.queries = append(bucket.queries, dq)
 						}
 						q.buckets.push(bucket)
 					}
@@ -223,11 +561,19 @@ func pathMatchOp(q *query, e *Eval, i *Item) queryStateFn {
 		}
 	}
 
+	// The query stays put; every matching node below it continues
+	// the rest of the path in a query of its own
+	if q.nextIsDescendant() {
+		q.matchDescendant(e, i, q.operators[q.loc()-q.start])
+	}
+
 	if q.loc() == len(q.operators)+q.start && q.loc() <= curLocation {
 		if q.captureEndValue {
 			q.firstType = i.typ
 			q.buffer.Write(i.val)
 		}
+		q.first = Item{pos: i.pos, line: i.line, column: i.column}
+		q.end = i.end()
 		q.valLoc = *e.location.clone()
 		return pathEndValue
 	}
@@ -240,12 +586,20 @@ func pathMatchOp(q *query, e *Eval, i *Item) queryStateFn {
 }
 
 func pathEndValue(q *query, e *Eval, i *Item) queryStateFn {
-	if e.location.len()-1 >= q.loc() {
+	// the root value ends with its document
+	if i.typ != jsonEOF && e.location.len()-1 >= q.loc() {
 		if q.captureEndValue {
 			q.buffer.Write(i.val)
 		}
+		q.end = i.end()
 	} else {
-		r := &Result{Keys: q.valLoc.toArray()}
+		r := &Result{
+			Keys:   q.valLoc.toArray(),
+			Offset: int64(q.first.pos),
+			Length: int(q.end - q.first.pos),
+			Line:   q.first.line,
+			Column: q.first.column,
+		}
 		if q.buffer.Len() > 0 {
 			val := make([]byte, q.buffer.Len())
 			copy(val, q.buffer.Bytes())
@@ -265,7 +619,7 @@ func pathEndValue(q *query, e *Eval, i *Item) queryStateFn {
 			case jsonNumber:
 				r.Type = JsonNumber
 			default:
-				r.Type = -1
+				r.Type = Unknown
 			}
 		}
 
@@ -276,32 +630,82 @@ func pathEndValue(q *query, e *Eval, i *Item) queryStateFn {
 			b.(exprBucket).results.push(r)
 		}
 
-		q.valLoc = *newStack()
+		q.valLoc = stack{}
 		q.buffer.Truncate(0)
 		q.pos -= 1
+		q.trySpillOver()
 		return pathMatchOp
 	}
 	return pathEndValue
 }
 
-func (b *exprBucket) evaluate() (bool, error) {
-	values := make(map[string]Item)
+func (q *query) nextIsDescendant() bool {
+	next := q.loc() - q.start
+	return next >= 0 && next < len(q.operators) && q.operators[next].descendant
+}
+
+// pushElement puts the results of the array element at index into a bucket
+// of the window of its array, creating the window on the first element.
+func (q *query) pushElement(index int) {
+	arrayLoc := q.loc() - 1
+	b, ok := q.buckets.peek()
+	if !ok || b.(exprBucket).window == nil || b.(exprBucket).operatorLoc != arrayLoc {
+		q.buckets.push(exprBucket{
+			operatorLoc: arrayLoc,
+			results:     newResults(),
+			window:      &indexWindow{op: q.operators[q.loc()-q.start-1]},
+		})
+	}
+	q.buckets.push(exprBucket{
+		operatorLoc: q.loc(),
+		results:     newResults(),
+		element:     true,
+		index:       index,
+	})
+}
+
+// kindTokens holds the token type that starts a value of each kind
+var kindTokens = [...]int{
+	Unknown:    jsonError,
+	JsonObject: jsonBraceLeft,
+	JsonArray:  jsonBracketLeft,
+	JsonString: jsonString,
+	JsonNumber: jsonNumber,
+	JsonNull:   jsonNull,
+	JsonBool:   jsonBool,
+}
+
+// evaluate decides the filter of the bucket for its candidate. env is cleared
+// and filled with the values of the paths in the filter.
+func (b *exprBucket) evaluate(env *filterEnv, roots map[string]*rootRef) (bool, error) {
+	env.reset(b.filter.usesNodes)
 	for _, q := range b.queries {
-		result := q.resultQueue.Pop()
-		if result != nil {
-			t, err := getJsonTokenType(result.Value)
-			if err != nil {
-				return false, err
-			}
+		key := q.Path.stringValue
+		q.releaseResults(q.resultQueue, func(result *Result) {
 			i := Item{
-				typ: t,
+				typ: kindTokens[result.Type],
 				val: result.Value,
 			}
-			values[q.Path.stringValue] = i
+			if _, ok := env.values[key]; !ok {
+				env.values[key] = i
+			}
+			if env.nodes != nil {
+				env.nodes[key] = append(env.nodes[key], i)
+			}
+		})
+	}
+	for _, p := range b.filter.dependentPaths {
+		if r, ok := roots[p.stringValue]; ok && p.isRoot() {
+			if len(r.nodes) > 0 {
+				env.values[p.stringValue] = r.nodes[0]
+			}
+			if env.nodes != nil {
+				env.nodes[p.stringValue] = r.nodes
+			}
 		}
 	}
 
-	res, err := evaluatePostFix(b.expression, values)
+	res, err := b.filter.compiled(env)
 	if err != nil {
 		return false, err
 	}
@@ -313,6 +717,15 @@ func (b *exprBucket) evaluate() (bool, error) {
 }
 
 func itemMatchOperator(loc interface{}, i *Item, op *operator) bool {
+	if op.typ == opTypeUnion {
+		for _, u := range op.union {
+			if itemMatchOperator(loc, i, u) {
+				return true
+			}
+		}
+		return false
+	}
+
 	topBytes, isKey := loc.([]byte)
 	topInt, isIndex := loc.(int)
 	if isKey {
@@ -324,12 +737,82 @@ func itemMatchOperator(loc interface{}, i *Item, op *operator) bool {
 			return found
 		}
 	} else if isIndex {
+		if op.windowed() {
+			// Decided by the window once the array length is known
+			return true
+		}
 		switch op.typ {
 		case opTypeIndexWild:
 			return true
-		case opTypeIndex, opTypeIndexRange:
-			return topInt >= op.indexStart && (!op.hasIndexEnd || topInt <= op.indexEnd)
+		case opTypeIndex:
+			return topInt == op.indexStart
+		case opTypeIndexRange:
+			return op.indexStep > 0 && topInt >= op.indexStart && (!op.hasIndexEnd || topInt < op.indexEnd) &&
+				(topInt-op.indexStart)%op.indexStep == 0
 		}
 	}
 	return false
 }
+
+type windowElement struct {
+	index   int
+	results *Results
+}
+
+// indexWindow holds the elements of an array that may still be selected by
+// an operator with negative bounds. Elements are released in order as soon as
+// enough of the array has been seen, so at most as many elements as the
+// largest negative bound are ever held. Slices with a negative step release
+// their elements in reverse once the array ends.
+type indexWindow struct {
+	op       *operator
+	length   int
+	elements []windowElement
+}
+
+func (w *indexWindow) add(index int, results *Results, spillover *Results) {
+	w.length = index + 1
+	if w.reversed() {
+		// Elements of a reversed slice are released at the end of the array.
+		// Whether one can be dropped early depends only on its index.
+		if _, known := w.op.selectIndex(index, w.length, false); !known {
+			w.elements = append(w.elements, windowElement{index, results})
+		}
+		return
+	}
+	w.elements = append(w.elements, windowElement{index, results})
+	w.release(spillover, false)
+}
+
+func (w *indexWindow) reversed() bool {
+	return w.op.typ == opTypeIndexRange && w.op.indexStep < 0
+}
+
+func (w *indexWindow) close(spillover *Results) {
+	w.release(spillover, true)
+}
+
+func (w *indexWindow) release(spillover *Results, final bool) {
+	if w.reversed() {
+		for x := len(w.elements) - 1; x >= 0; x-- {
+			el := w.elements[x]
+			if selected, _ := w.op.selectIndex(el.index, w.length, true); selected {
+				moveResults(el.results, spillover)
+			}
+		}
+		w.elements = nil
+		return
+	}
+
+	for len(w.elements) > 0 {
+		el := w.elements[0]
+		selected, known := w.op.selectIndex(el.index, w.length, final)
+		if !known {
+			return
+		}
+		if selected {
+			moveResults(el.results, spillover)
+		}
+		w.elements = w.elements[1:]
+	}
+}
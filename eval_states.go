@@ -1,12 +1,15 @@
 package jsonpath
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 )
 
 func evalRoot(e *Eval, i *Item) evalStateFn {
 	switch i.typ {
+	case jsonNull, jsonNumber, jsonString, jsonBool:
+		return evalRootEnd
 	case jsonBraceLeft:
 		e.levelStack.push(i.typ)
 		return evalObjectAfterOpen
@@ -25,7 +28,14 @@ func evalObjectAfterOpen(e *Eval, i *Item) evalStateFn {
 	switch i.typ {
 	case jsonKey:
 		c := i.val[1 : len(i.val)-1]
-		if e.copyValues {
+		if bytes.IndexByte(c, '\\') >= 0 {
+			key, err := unquoteString(i.val)
+			if err != nil {
+				e.Error = fmt.Errorf("%s at byte index %d (line %d, column %d)", err.Error(), i.pos, i.line, i.column)
+				return nil
+			}
+			c = []byte(key)
+		} else if e.copyValues {
 			d := make([]byte, len(c))
 			copy(d, c)
 			c = d
@@ -188,6 +198,6 @@ func evalRootEnd(e *Eval, i *Item) evalStateFn {
 }
 
 func evalError(e *Eval, i *Item) evalStateFn {
-	e.Error = fmt.Errorf("%s at byte index %d", string(i.val), i.pos)
+	e.Error = fmt.Errorf("%s at byte index %d (line %d, column %d)", string(i.val), i.pos, i.line, i.column)
 	return nil
 }
@@ -1,8 +1,11 @@
 package jsonpath
 
 import (
+	"context"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -20,12 +23,45 @@ var tests = []test{
 	test{`empty array`, `{"aKey":[]}`, `$.aKey+`, []Result{newResult(`[]`, JsonArray, `aKey`)}},
 	test{`multiple same-level keys, weird spacing`, `{    "aKey" 	: true ,    "bKey":  [	1 , 2	], "cKey" 	: true		} `, `$.bKey+`, []Result{newResult(`[1,2]`, JsonArray, `bKey`)}},
 
+	test{`root object`, `{"aKey":32}`, `$+`, []Result{newResult(`{"aKey":32}`, JsonObject)}},
+	test{`string root`, ` "hello" `, `$+`, []Result{newResult(`"hello"`, JsonString)}},
+	test{`number root`, `42`, `$+`, []Result{newResult(`42`, JsonNumber)}},
+	test{`null root`, `null`, `$+`, []Result{newResult(`null`, JsonNull)}},
+	test{`key selection on scalar root`, `true`, `$.aKey+`, []Result{}},
+
 	test{`array index selection`, `{"aKey":[123,456]}`, `$.aKey[1]+`, []Result{newResult(`456`, JsonNumber, `aKey`, 1)}},
 	test{`array wild index selection`, `{"aKey":[123,456]}`, `$.aKey[*]+`, []Result{newResult(`123`, JsonNumber, `aKey`, 0), newResult(`456`, JsonNumber, `aKey`, 1)}},
 	test{`array range index selection`, `{"aKey":[11,22,33,44]}`, `$.aKey[1:3]+`, []Result{newResult(`22`, JsonNumber, `aKey`, 1), newResult(`33`, JsonNumber, `aKey`, 2)}},
 	test{`array range (no index) selection`, `{"aKey":[11,22,33,44]}`, `$.aKey[1:1]+`, []Result{}},
 	test{`array range (no upper bound) selection`, `{"aKey":[11,22,33]}`, `$.aKey[1:]+`, []Result{newResult(`22`, JsonNumber, `aKey`, 1), newResult(`33`, JsonNumber, `aKey`, 2)}},
 
+	test{`array last index selection`, `{"aKey":[11,22,33,44]}`, `$.aKey[-1]+`, []Result{newResult(`44`, JsonNumber, `aKey`, 3)}},
+	test{`array negative index selection`, `{"aKey":[11,22,33,44]}`, `$.aKey[-3]+`, []Result{newResult(`22`, JsonNumber, `aKey`, 1)}},
+	test{`array negative index out of range`, `{"aKey":[11,22]}`, `$.aKey[-3]+`, []Result{}},
+	test{`array negative range (no upper bound) selection`, `{"aKey":[11,22,33,44]}`, `$.aKey[-2:]+`, []Result{newResult(`33`, JsonNumber, `aKey`, 2), newResult(`44`, JsonNumber, `aKey`, 3)}},
+	test{`array negative upper bound selection`, `{"aKey":[11,22,33,44]}`, `$.aKey[1:-1]+`, []Result{newResult(`22`, JsonNumber, `aKey`, 1), newResult(`33`, JsonNumber, `aKey`, 2)}},
+	test{`array negative range selection`, `{"aKey":[11,22,33,44]}`, `$.aKey[-3:-2]+`, []Result{newResult(`22`, JsonNumber, `aKey`, 1)}},
+	test{`array negative index then key`, `[{"a":1},{"a":2},{"a":3}]`, `$[-1].a+`, []Result{newResult(`3`, JsonNumber, 2, `a`)}},
+	test{`recursive descent negative index`, `{"x":[1,[2,3]],"y":[4,5]}`, `$..[-1]+`, []Result{newResult(`[2,3]`, JsonArray, `x`, 1), newResult(`3`, JsonNumber, `x`, 1, 1), newResult(`5`, JsonNumber, `y`, 1)}},
+	test{`recursive descent negative index in root array`, `[[1,2,3],[4,5]]`, `$..[-1]+`, []Result{newResult(`[4,5]`, JsonArray, 1), newResult(`3`, JsonNumber, 0, 2), newResult(`5`, JsonNumber, 1, 1)}},
+	test{`recursive descent reversed slice in root array`, `[[1,2],[3]]`, `$..[::-1]+`, []Result{newResult(`[3]`, JsonArray, 1), newResult(`[1,2]`, JsonArray, 0), newResult(`2`, JsonNumber, 0, 1), newResult(`1`, JsonNumber, 0, 0), newResult(`3`, JsonNumber, 1, 0)}},
+	test{`recursive descent negative index below a filter`, `{"a":[[1,2],[5,6],[7,8]]}`, `$.a[?(@[0] == 7)]..[-1]+`, []Result{newResult(`8`, JsonNumber, `a`, 2, 1)}},
+	test{`recursive descent negative index below a rejecting filter`, `{"a":[1,[5,6],3]}`, `$.a[?(@ > 100)]..[-1]+`, []Result{}},
+	test{`recursive descent negative index below a negative index`, `{"a":[[1,2],[3,4]]}`, `$.a[-1]..[-1]+`, []Result{newResult(`4`, JsonNumber, `a`, 1, 1)}},
+	test{`recursive descent negative index below a reversed slice`, `{"a":[[1,2],[3,4]]}`, `$.a[::-1]..[-1]+`, []Result{newResult(`4`, JsonNumber, `a`, 1, 1), newResult(`2`, JsonNumber, `a`, 0, 1)}},
+	test{`array slice with step`, `{"aKey":[0,1,2,3,4,5]}`, `$.aKey[1:6:2]+`, []Result{newResult(`1`, JsonNumber, `aKey`, 1), newResult(`3`, JsonNumber, `aKey`, 3), newResult(`5`, JsonNumber, `aKey`, 5)}},
+	test{`array slice with defaults`, `{"aKey":[0,1,2,3]}`, `$.aKey[:2]+`, []Result{newResult(`0`, JsonNumber, `aKey`, 0), newResult(`1`, JsonNumber, `aKey`, 1)}},
+	test{`array slice reversed`, `{"aKey":[0,1,2]}`, `$.aKey[::-1]+`, []Result{newResult(`2`, JsonNumber, `aKey`, 2), newResult(`1`, JsonNumber, `aKey`, 1), newResult(`0`, JsonNumber, `aKey`, 0)}},
+	test{`array slice negative step with bounds`, `{"aKey":[0,1,2,3,4,5]}`, `$.aKey[5:1:-2]+`, []Result{newResult(`5`, JsonNumber, `aKey`, 5), newResult(`3`, JsonNumber, `aKey`, 3)}},
+	test{`array slice zero step`, `{"aKey":[0,1,2]}`, `$.aKey[0:3:0]+`, []Result{}},
+	test{`escaped key`, `{"a\"b":1,"caf\u00e9":2,"\ud83d\ude00":3}`, `$["a\"b"]+`, []Result{newResult(`1`, JsonNumber, `a"b`)}},
+	test{`unicode escaped key`, `{"a\"b":1,"caf\u00e9":2,"\ud83d\ude00":3}`, `$["café"]+`, []Result{newResult(`2`, JsonNumber, `café`)}},
+	test{`surrogate pair key`, `{"a\"b":1,"caf\u00e9":2,"\ud83d\ude00":3}`, `$["\ud83d\ude00"]+`, []Result{newResult(`3`, JsonNumber, "\U0001F600")}},
+	test{`backslash key`, `{"\\":{"x":1}}`, `$["\\"].x+`, []Result{newResult(`1`, JsonNumber, `\`, `x`)}},
+	test{`key union selection`, `{"aKey":1,"bKey":2,"cKey":3}`, `$["aKey","cKey"]+`, []Result{newResult(`1`, JsonNumber, `aKey`), newResult(`3`, JsonNumber, `cKey`)}},
+	test{`index union selection`, `{"aKey":[11,22,33,44,55,66]}`, `$.aKey[0,3,5]+`, []Result{newResult(`11`, JsonNumber, `aKey`, 0), newResult(`44`, JsonNumber, `aKey`, 3), newResult(`66`, JsonNumber, `aKey`, 5)}},
+	test{`mixed union selection`, `{"aKey":[11,22,33,44,55,66]}`, `$.aKey[0,"name",2:4, -1]+`, []Result{newResult(`11`, JsonNumber, `aKey`, 0), newResult(`33`, JsonNumber, `aKey`, 2), newResult(`44`, JsonNumber, `aKey`, 3), newResult(`66`, JsonNumber, `aKey`, 5)}},
+	test{`mixed union selection on object`, `{"aKey":{"name":1,"other":2}}`, `$.aKey[0,"name",2:4]+`, []Result{newResult(`1`, JsonNumber, `aKey`, `name`)}},
 	test{`empty array - try selection`, `{"aKey":[]}`, `$.aKey[1]+`, []Result{}},
 	test{`null selection`, `{"aKey":[null]}`, `$.aKey[0]+`, []Result{newResult(`null`, JsonNull, `aKey`, 0)}},
 	test{`empty object`, `{"aKey":{}}`, `$.aKey+`, []Result{newResult(`{}`, JsonObject, `aKey`)}},
@@ -39,36 +75,58 @@ var tests = []test{
 	test{`multi-level array`, `{"aKey":[true,false,null,{"michael":[5,6,7]}, ["s", "3"] ]}`, `$.*[*].michael[1]+`, []Result{newResult(`6`, JsonNumber, `aKey`, 3, `michael`, 1)}},
 	test{`multi-level array 2`, `{"aKey":[true,false,null,{"michael":[5,6,7]}, ["s", "3"] ]}`, `$.*[*][1]+`, []Result{newResult(`"3"`, JsonString, `aKey`, 4, 1)}},
 
+	test{`recursive descent key`, `{"a":{"a":1,"b":[{"a":2}]},"c":3}`, `$..a+`, []Result{newResult(`{"a":1,"b":[{"a":2}]}`, JsonObject, `a`), newResult(`1`, JsonNumber, `a`, `a`), newResult(`2`, JsonNumber, `a`, `b`, 0, `a`)}},
+	test{`recursive descent index`, `{"a":[1,[2,3]],"b":{"c":[4]}}`, `$..[0]+`, []Result{newResult(`1`, JsonNumber, `a`, 0), newResult(`2`, JsonNumber, `a`, 1, 0), newResult(`4`, JsonNumber, `b`, `c`, 0)}},
+	test{`recursive descent wildcard`, `{"a":{"b":1},"c":[2]}`, `$..*+`, []Result{newResult(`{"b":1}`, JsonObject, `a`), newResult(`1`, JsonNumber, `a`, `b`), newResult(`[2]`, JsonArray, `c`)}},
+	test{`recursive descent then child`, `{"a":{"a":[5,6],"b":[{"a":[7]}]}}`, `$..a[*]+`, []Result{newResult(`5`, JsonNumber, `a`, `a`, 0), newResult(`6`, JsonNumber, `a`, `a`, 1), newResult(`7`, JsonNumber, `a`, `b`, 0, `a`, 0)}},
+	test{`recursive descent after where clause`, `{"items":[{"n":1,"x":{"id":"a"}},{"n":2,"x":{"id":"b"}}]}`, `$.items[*]?(@.n == 2)..id+`, []Result{newResult(`"b"`, JsonString, `items`, 1, `x`, `id`)}},
+	test{`recursive descent with where clause`, `{"s":{"book":[{"price":8,"t":"x"},{"price":12,"t":"y"}]}}`, `$..book[*]?(@.price < 10).t+`, []Result{newResult(`"x"`, JsonString, `s`, `book`, 0, `t`)}},
+
 	test{`evaluation literal equality`, `{"items":[ {"name":"alpha", "value":11}]}`, `$.items[*]?("bravo" == "bravo").value+`, []Result{newResult(`11`, JsonNumber, `items`, 0, `value`)}},
 	test{`evaluation based on string equal to path value`, `{"items":[ {"name":"alpha", "value":11}, {"name":"bravo", "value":22}, {"name":"charlie", "value":33} ]}`, `$.items[*]?(@.name == "bravo").value+`, []Result{newResult(`22`, JsonNumber, `items`, 1, `value`)}},
+	test{`evaluation with single quoted string`, `{"items":[ {"name":"alpha", "value":11}, {"name":"bravo", "value":22} ]}`, `$.items[*]?(@.name=='bravo').value+`, []Result{newResult(`22`, JsonNumber, `items`, 1, `value`)}},
+	test{`evaluation on bool path value`, `{"items":[ {"ok":false, "value":11}, {"ok":true, "value":22} ]}`, `$.items[*]?(@.ok == true).value+`, []Result{newResult(`22`, JsonNumber, `items`, 1, `value`)}},
+	test{`evaluation with regex match`, `{"items":[ {"name":"alpha", "value":11}, {"name":"Bravo", "value":22} ]}`, `$.items[*]?(@.name =~ /^b(r)/i).value+`, []Result{newResult(`22`, JsonNumber, `items`, 1, `value`)}},
+	test{`evaluation with list membership`, `{"items":[ {"name":"alpha", "value":11}, {"name":"bravo", "value":22} ]}`, `$.items[*]?(@.name in ['alpha', 'charlie']).value+`, []Result{newResult(`11`, JsonNumber, `items`, 0, `value`)}},
+	test{`evaluation with string ordering`, `{"items":[ {"name":"alpha", "value":11}, {"name":"bravo", "value":22} ]}`, `$.items[*]?(@.name >= 'b').value+`, []Result{newResult(`22`, JsonNumber, `items`, 1, `value`)}},
+	test{`evaluation with substring`, `{"items":[ {"name":"alpha", "value":11}, {"name":"bravo", "value":22} ]}`, `$.items[*]?(@.name contains 'ph' || @.name endsWith 'vo').value+`, []Result{newResult(`11`, JsonNumber, `items`, 0, `value`), newResult(`22`, JsonNumber, `items`, 1, `value`)}},
+	test{`evaluation on captured value`, `{"items":[ {"name":"alpha"}, {"name":"bravo"} ]}`, `$.items[*]?(@.name == "bravo")+`, []Result{newResult(`{"name":"bravo"}`, JsonObject, `items`, 1)}},
+	test{`evaluation after negative index`, `{"items":[ {"name":"alpha", "value":11}, {"name":"bravo", "value":22}, {"name":"charlie", "value":33} ]}`, `$.items[-2:]?(@.name == "bravo").value+`, []Result{newResult(`22`, JsonNumber, `items`, 1, `value`)}},
+	test{`evaluation on member existence`, `{"items":[ {"tags":[], "value":11}, {"value":22}, {"tags":null, "value":33} ]}`, `$.items[*]?(@.tags).value+`, []Result{newResult(`11`, JsonNumber, `items`, 0, `value`), newResult(`33`, JsonNumber, `items`, 2, `value`)}},
+	test{`evaluation on negated existence`, `{"items":[ {"deleted":true, "value":11}, {"value":22}, {"deleted":false, "value":33} ]}`, `$.items[*]?(!@.deleted).value+`, []Result{newResult(`22`, JsonNumber, `items`, 1, `value`), newResult(`33`, JsonNumber, `items`, 2, `value`)}},
+	test{`evaluation on array equality`, `{"items":[ {"tags":["a"], "value":11}, {"tags":["a","b"], "value":22}, {"tags":"a", "value":33} ]}`, `$.items[*]?(@.tags == ["a","b"]).value+`, []Result{newResult(`22`, JsonNumber, `items`, 1, `value`)}},
+	test{`evaluation on object equality`, `{"items":[ {"x":{"a":1,"b":[2]}, "y":{"b":[2],"a":1}}, {"x":{"a":1}, "y":{"a":2}} ]}`, `$.items[*]?(@.x == @.y).x.a+`, []Result{newResult(`1`, JsonNumber, `items`, 0, `x`, `a`)}},
+	test{`evaluation skipping the right operand of ||`, `{"items":[ {"v":1}, {"x":{"y":2}, "v":2}, {"x":{"y":0}, "v":3} ]}`, `$.items[*]?(!@.x || @.x.y > 1).v+`, []Result{newResult(`1`, JsonNumber, `items`, 0, `v`), newResult(`2`, JsonNumber, `items`, 1, `v`)}},
+	test{`evaluation testing a value against null first`, `{"a":[{"x":{"y":2}},{"z":1}]}`, `$.a[*]?(@.x != null && @.x.y > 1)+`, []Result{newResult(`{"x":{"y":2}}`, JsonObject, `a`, 0)}},
+	test{`evaluation comparing a number with null`, `{"a":[{"x":3},{"x":null}]}`, `$.a[*]?(@.x != null).x+`, []Result{newResult(`3`, JsonNumber, `a`, 0, `x`)}},
+	test{`evaluation comparing values of different types`, `{"a":[{"x":"1"},{"x":1},{"x":[1]}]}`, `$.a[*]?(@.x == 1).x+`, []Result{newResult(`1`, JsonNumber, `a`, 1, `x`)}},
+	test{`bracket filter`, `{"items":[ {"price":8, "name":"alpha"}, {"price":12, "name":"bravo"} ]}`, `$.items[?(@.price > 10)].name+`, []Result{newResult(`"bravo"`, JsonString, `items`, 1, `name`)}},
+	test{`bracket filter on members`, `{"items":{"a":{"price":8}, "b":{"price":12}}}`, `$.items[?(@.price > 10)]+`, []Result{newResult(`{"price":12}`, JsonObject, `items`, `b`)}},
+	test{`bracket filter on scalars`, `{"items":[1, 5, "x", 3, 7]}`, `$.items[?(@ > 3)]+`, []Result{newResult(`5`, JsonNumber, `items`, 1), newResult(`7`, JsonNumber, `items`, 4)}},
+	test{`bracket filter with index`, `{"items":[1, 5, 2, 3, 7]}`, `$.items[?(@ > 4),0]+`, []Result{newResult(`1`, JsonNumber, `items`, 0), newResult(`5`, JsonNumber, `items`, 1), newResult(`7`, JsonNumber, `items`, 4)}},
+	test{`bracket filters in one bracket`, `{"items":{"a":{"x":1}, "b":{"x":2}, "c":{"x":3}, "d":{"x":4}}}`, `$.items[?(@.x == 1),"c",?(@.x == 2)]+`, []Result{newResult(`{"x":1}`, JsonObject, `items`, `a`), newResult(`{"x":2}`, JsonObject, `items`, `b`), newResult(`{"x":3}`, JsonObject, `items`, `c`)}},
+	test{`where clause on scalars`, `{"items":["a", "b", "c"]}`, `$.items[*]?(@ != "b")+`, []Result{newResult(`"a"`, JsonString, `items`, 0), newResult(`"c"`, JsonString, `items`, 2)}},
+	test{`evaluation with root value before candidates`, `{"user":"b","items":[ {"owner":"a", "value":11}, {"owner":"b", "value":22} ]}`, `$.items[*]?(@.owner == $.user).value+`, []Result{newResult(`22`, JsonNumber, `items`, 1, `value`)}},
+	test{`evaluation with root value after candidates`, `{"items":[ {"owner":"a", "value":11}, {"owner":"b", "value":22}, {"owner":"b", "value":33} ],"user":"b"}`, `$.items[*]?(@.owner == $.user).value+`, []Result{newResult(`22`, JsonNumber, `items`, 1, `value`), newResult(`33`, JsonNumber, `items`, 2, `value`)}},
+	test{`evaluation with root values in nested filters`, `{"a":[ {"x":1, "b":[{"y":2, "z":"p"}, {"y":3, "z":"q"}]}, {"x":2, "b":[{"y":3, "z":"r"}]} ],"p":1,"q":3}`, `$.a[*]?(@.x == $.p).b[*]?(@.y == $.q).z+`, []Result{newResult(`"q"`, JsonString, `a`, 0, `b`, 1, `z`)}},
+	test{`recursive descent with root value`, `{"s":{"book":[{"price":8,"t":"x"},{"price":12,"t":"y"}]},"limit":{"max":10}}`, `$..book[*]?(@.price < $.limit.max).t+`, []Result{newResult(`"x"`, JsonString, `s`, `book`, 0, `t`)}},
 }
 
 func TestPathQuery(t *testing.T) {
 	as := assert.New(t)
 
 	for _, t := range tests {
-		paths, err := ParsePaths(t.path)
-		if as.NoError(err) {
-			eval, err := EvalPathsInBytes([]byte(t.json), paths)
-			if as.NoError(err, "Testing: %s", t.name) {
-				res := toResultArray(eval)
-				if as.NoError(eval.Error) {
-					as.EqualValues(t.expected, res, "Testing of %q", t.name)
-				}
-			}
-
-			eval_reader, err := EvalPathsInReader(strings.NewReader(t.json), paths)
-			if as.NoError(err, "Testing: %s", t.name) {
-				res := toResultArray(eval_reader)
-				if as.NoError(eval.Error) {
-					as.EqualValues(t.expected, res, "Testing of %q", t.name)
-				}
+		for _, results := range evalResults(as, t.path, bytesAndReader(t.json)) {
+			// positions are covered by TestResultPositions
+			for x := range results {
+				results[x].Offset, results[x].Length, results[x].Line, results[x].Column = 0, 0, 0, 0
 			}
+			as.EqualValues(t.expected, results, "Testing of %q", t.name)
 		}
 	}
 }
 
-func newResult(value string, typ int, keys ...interface{}) Result {
+func newResult(value string, typ Kind, keys ...interface{}) Result {
 	keysChanged := make([]interface{}, len(keys))
 	for i, k := range keys {
 		switch v := k.(type) {
@@ -86,16 +144,397 @@ func newResult(value string, typ int, keys ...interface{}) Result {
 	}
 }
 
-func toResultArray(e *Eval) []Result {
+// newEvalFunc starts evaluating paths on an input
+type newEvalFunc func(paths []*Path) (*Eval, error)
+
+// bytesAndReader evaluates input with both the slice and the reader lexer
+func bytesAndReader(input string, opts ...Options) []newEvalFunc {
+	return []newEvalFunc{
+		func(paths []*Path) (*Eval, error) {
+			return EvalPathsInBytes([]byte(input), paths, opts...)
+		},
+		func(paths []*Path) (*Eval, error) {
+			return EvalPathsInReader(strings.NewReader(input), paths, opts...)
+		},
+	}
+}
+
+// evalResults evaluates path with each of evals and returns the results of
+// every evaluation that succeeded. Errors are reported to as.
+func evalResults(as *assert.Assertions, path string, evals []newEvalFunc) [][]Result {
+	paths, err := ParsePaths(path)
+	if !as.NoError(err, path) {
+		return nil
+	}
+	all := make([][]Result, 0, len(evals))
+	for _, newEval := range evals {
+		eval, err := newEval(paths)
+		if !as.NoError(err, path) {
+			continue
+		}
+		results, err := drainResults(eval)
+		if as.NoError(err, path) {
+			all = append(all, results)
+		}
+	}
+	return all
+}
+
+// drainResults reads every result of an evaluation
+func drainResults(e *Eval) ([]Result, error) {
 	vals := make([]Result, 0)
 	for {
-		if r, ok := e.Next(); ok {
-			if r != nil {
-				vals = append(vals, *r)
+		r, ok := e.Next()
+		if !ok {
+			break
+		}
+		vals = append(vals, *r)
+	}
+	return vals, e.Error
+}
+
+type position struct {
+	offset, length, line, column int
+}
+
+func TestResultPositions(t *testing.T) {
+	as := assert.New(t)
+
+	doc := "{\n  \"a\": [1, { \"b\" : \"x\" } ],\n  \"c\":\n\t\ttrue\n}"
+	cases := []struct {
+		path     string
+		expected []position
+	}{
+		{`$.a+`, []position{{9, 19, 2, 8}}},
+		{`$.a[*]+`, []position{{10, 1, 2, 9}, {13, 13, 2, 12}}},
+		{`$.a[1].b`, []position{{21, 3, 2, 20}}},
+		{`$.c+`, []position{{39, 4, 4, 3}}},
+		{`$.a[*]?(@.b == "x")+`, []position{{13, 13, 2, 12}}},
+	}
+
+	for _, c := range cases {
+		for _, results := range evalResults(as, c.path, bytesAndReader(doc)) {
+			actual := make([]position, 0, len(results))
+			for _, r := range results {
+				if r.Value != nil {
+					as.Equal(string(stripSpace(doc[r.Offset:int(r.Offset)+r.Length])), string(r.Value), c.path)
+				}
+				actual = append(actual, position{int(r.Offset), r.Length, r.Line, r.Column})
+			}
+			as.Equal(c.expected, actual, c.path)
+		}
+	}
+}
+
+func mustEval(eval *Eval, err error) *Eval {
+	if err != nil {
+		panic(err)
+	}
+	return eval
+}
+
+func stripSpace(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range []byte(s) {
+		if r != ' ' && r != '\t' && r != '\n' {
+			b = append(b, r)
+		}
+	}
+	return b
+}
+
+type documentValue struct {
+	document int
+	value    string
+	line     int
+}
+
+func TestDocuments(t *testing.T) {
+	as := assert.New(t)
+
+	lines := "{\"a\":1,\"items\":[3,7],\"min\":5}\n\n[{\"a\":2}]\n{\"items\":[9,1],\"min\":0,\"a\":3}{\"a\":4}\n"
+	seq := "\x1e{\"a\":1,\"items\":[3,7],\"min\":5}\n\n\x1e\x1e[{\"a\":2}]\n\x1e{\"items\":[9,1],\"min\":0,\"a\":3}\x1e{\"a\":4}\n"
+	cases := []struct {
+		path     string
+		expected []documentValue
+	}{
+		{`$.a+`, []documentValue{{0, `1`, 1}, {2, `3`, 4}, {3, `4`, 4}}},
+		{`$..a+`, []documentValue{{0, `1`, 1}, {1, `2`, 3}, {2, `3`, 4}, {3, `4`, 4}}},
+		{`$.items[?(@ > $.min)]+`, []documentValue{{0, `7`, 1}, {2, `9`, 4}, {2, `1`, 4}}},
+	}
+
+	evals := []newEvalFunc{
+		func(paths []*Path) (*Eval, error) {
+			return EvalPathsInJSONLines(strings.NewReader(lines), paths)
+		},
+		func(paths []*Path) (*Eval, error) {
+			return EvalPathsInJSONSeq(strings.NewReader(seq), paths)
+		},
+	}
+	for _, c := range cases {
+		for _, results := range evalResults(as, c.path, evals) {
+			actual := make([]documentValue, 0, len(results))
+			for _, r := range results {
+				actual = append(actual, documentValue{r.Document, string(r.Value), r.Line})
+			}
+			as.Equal(c.expected, actual, c.path)
+		}
+	}
+
+	for input, msg := range map[string]string{
+		"{\"a\":1}\n{\"a\":}\n": "Unexpected character as start of value: U+007D '}' at byte index 13 (line 2, column 6)",
+		"{\"a\":1}\n}\n":        "Unexpected character as start of value: U+007D '}' at byte index 8 (line 2, column 1)",
+	} {
+		paths, _ := ParsePaths(`$.a+`)
+		_, err := drainResults(mustEval(EvalPathsInJSONLines(strings.NewReader(input), paths)))
+		as.EqualError(err, msg, input)
+	}
+
+	paths, _ := ParsePaths(`$.a+`)
+	eval := mustEval(EvalPathsInJSONSeq(strings.NewReader("\x1e{\"a\":1}\n{\"a\":2}\n"), paths))
+	r, ok := eval.Next()
+	if as.True(ok) {
+		as.Equal(`1`, string(r.Value))
+	}
+	_, ok = eval.Next()
+	as.False(ok)
+	as.EqualError(eval.Error, "Expected record separator before document instead of U+007B '{' at byte index 9 (line 2, column 1)")
+}
+
+func TestValidate(t *testing.T) {
+	as := assert.New(t)
+
+	as.NoError(Validate(strings.NewReader(`{"a":[1,2,{"b":null}],"c":"é"}`)))
+	as.NoError(Validate(strings.NewReader(` 42 `)))
+
+	for input, msg := range map[string]string{
+		``:                     "Unexpected EOF instead of value at byte index 0 (line 1, column 1)",
+		`{"a":1,}`:             "Expected \" as start of string instead of U+007D '}' at byte index 7 (line 1, column 8)",
+		"{\"a\":\n\"b\\q\"}":   "Invalid escape character U+0071 'q' in string at byte index 8 (line 2, column 3)",
+		`{"a":1} {"b":2}`:      "Unexpected character after json value token: U+007B '{' at byte index 8 (line 1, column 9)",
+		`[1,2,3,4,5,6,7,8,9,0`: "Unexpected EOF instead of value at byte index 20 (line 1, column 21)",
+		`["\%"]`:               "Invalid escape character U+0025 '%' in string at byte index 2 (line 1, column 3)",
+		`[1.]`:                 "Expected digit after '.' instead of U+005D ']' at byte index 3 (line 1, column 4)",
+		`[1e+x]`:               "Expected digit after numeric sign instead of U+0078 'x' at byte index 4 (line 1, column 5)",
+		`[-a]`:                 "Expected digit after dash instead of U+0061 'a' at byte index 2 (line 1, column 3)",
+		`1.`:                   "Expected digit after '.' instead of EOF at byte index 2 (line 1, column 3)",
+		`-`:                    "Expected digit after dash instead of EOF at byte index 1 (line 1, column 2)",
+		`1e`:                   "Expected digit after 'e' instead of EOF at byte index 2 (line 1, column 3)",
+	} {
+		as.EqualError(Validate(strings.NewReader(input)), msg, input)
+	}
+
+	// strict evaluations read past the last result to report errors
+	paths, _ := ParsePaths(`$.a+`)
+	eval := mustEval(EvalPathsInBytes([]byte(`{"a":1,"b":007}`), paths, Options{Strict: true}))
+	r, ok := eval.Next()
+	if as.True(ok) {
+		as.Equal(`1`, string(r.Value))
+	}
+	_, ok = eval.Next()
+	as.False(ok)
+	as.EqualError(eval.Error, "Unexpected digit U+0030 '0' after leading zero at byte index 12 (line 1, column 13)")
+}
+
+func TestLenientInput(t *testing.T) {
+	as := assert.New(t)
+
+	config := `{
+	// servers to connect to
+	servers: [
+		{name: 'primary', port: 0x1F90, tags: ['a', 'b',],},
+		{name: "backup", /* disabled */ port: 8081},
+	],
+}`
+	opts := Options{Comments: true, TrailingCommas: true, SingleQuotes: true, UnquotedKeys: true, HexNumbers: true}
+	cases := []struct {
+		path     string
+		expected []string
+	}{
+		{`$.servers[*].name+`, []string{`"primary"`, `"backup"`}},
+		{`$.servers[?(@.port == 8080)].tags+`, []string{`["a","b"]`}},
+		{`$.servers[0]+`, []string{`{"name":"primary","port":8080,"tags":["a","b"]}`}},
+	}
+	for _, c := range cases {
+		for _, results := range evalResults(as, c.path, bytesAndReader(config, opts)) {
+			actual := make([]string, 0, len(results))
+			for _, r := range results {
+				actual = append(actual, string(r.Value))
 			}
+			as.Equal(c.expected, actual, c.path)
+		}
+	}
+
+	// offsets and lengths refer to the input as written
+	paths, _ := ParsePaths(`$.servers[0].port+`, `$.servers[0].tags+`)
+	eval := mustEval(EvalPathsInBytes([]byte(config), paths, opts))
+	for {
+		r, ok := eval.Next()
+		if !ok {
+			break
+		}
+		written := config[r.Offset : r.Offset+int64(r.Length)]
+		switch string(r.Value) {
+		case `8080`:
+			as.Equal(`0x1F90`, written)
+		case `["a","b"]`:
+			as.Equal(`['a', 'b',]`, written)
+		default:
+			as.Fail("unexpected result", string(r.Value))
+		}
+	}
+	as.NoError(eval.Error)
+
+	_, err := drainResults(mustEval(EvalPathsInBytes([]byte(config), paths, Options{Comments: true})))
+	as.EqualError(err, "Expected '}' or \" within an object instead of U+0073 's' at byte index 29 (line 3, column 2)")
+}
+
+// endlessArray reads as [1,1,1,... without an end
+type endlessArray struct {
+	started bool
+}
+
+func (r *endlessArray) Read(p []byte) (int, error) {
+	for x := range p {
+		switch {
+		case !r.started:
+			p[x] = '['
+			r.started = true
+		case x%2 == 0:
+			p[x] = ','
+		default:
+			p[x] = '1'
+		}
+	}
+	return len(p), nil
+}
+
+func TestFilterError(t *testing.T) {
+	as := assert.New(t)
+
+	doc := `{"a":[{"x":2,"id":1},{"id":2},{"x":0,"id":3},{"x":4,"id":4}]}`
+	cases := []struct {
+		path  string
+		rfc   bool
+		ids   []string
+		nodes int
+		first string
+	}{
+		{`$.a[*]?(@.x > 1).id+`, false, []string{`1`, `4`}, 1, `Value for "@.x" not found`},
+		{`$.a[*]?(8 / @.x > 3).id+`, false, []string{`1`}, 2, `Value for "@.x" not found`},
+		{`$.a[?(@.id > 2)].id+`, false, []string{`3`, `4`}, 0, ``},
+		{`$.a[?@.x > 1].id`, true, []string{`1`, `4`}, 0, ``},
+	}
+	for _, c := range cases {
+		var paths []*Path
+		var err error
+		if c.rfc {
+			paths, err = ParsePathsRFC9535(c.path)
 		} else {
+			paths, err = ParsePaths(c.path)
+		}
+		if !as.NoError(err, c.path) {
+			continue
+		}
+		eval := mustEval(EvalPathsInBytes([]byte(doc), paths))
+		results, err := drainResults(eval)
+		as.NoError(err, c.path)
+		ids := make([]string, 0, len(results))
+		for _, r := range results {
+			ids = append(ids, string(r.Value))
+		}
+		as.Equal(c.ids, ids, c.path)
+
+		failed := eval.FilterError()
+		if c.nodes == 0 {
+			as.Nil(failed, c.path)
+		} else if as.NotNil(failed, c.path) {
+			as.Equal(c.nodes, failed.Nodes, c.path)
+			as.EqualError(failed.First, c.first, c.path)
+		}
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	as := assert.New(t)
+	paths, _ := ParsePaths(`$[*]+`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	eval := mustEval(EvalPathsInReaderContext(ctx, &endlessArray{}, paths))
+	for x := 0; x < 3; x++ {
+		r, ok := eval.Next()
+		if as.True(ok) {
+			as.Equal(`1`, string(r.Value))
+		}
+	}
+	cancel()
+	_, ok := eval.Next()
+	as.False(ok)
+	as.Equal(context.Canceled, eval.Error)
+	_, ok = eval.Next()
+	as.False(ok)
+
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	eval = mustEval(EvalPathsInReaderContext(ctx, strings.NewReader(`[1,2]`), paths))
+	_, ok = eval.Next()
+	as.False(ok)
+	as.Equal(context.DeadlineExceeded, eval.Error)
+
+	eval = mustEval(EvalPathsInReaderContext(context.Background(), strings.NewReader(`[1,2]`), paths))
+	values := []string{}
+	for {
+		r, ok := eval.Next()
+		if !ok {
 			break
 		}
+		values = append(values, string(r.Value))
+	}
+	as.NoError(eval.Error)
+	as.Equal([]string{`1`, `2`}, values)
+}
+
+// benchArray is a document of one array holding n objects
+func benchArray(n int) []byte {
+	var sb strings.Builder
+	sb.WriteString(`{"a":[`)
+	for x := 0; x < n; x++ {
+		if x > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `{"id":%d,"x":2}`, x)
+	}
+	sb.WriteString(`]}`)
+	return []byte(sb.String())
+}
+
+// benchmarkPath evaluates path on arrays of each size, so the growth of the
+// time per element shows
+func benchmarkPath(b *testing.B, path string, sizes ...int) {
+	paths, err := ParsePaths(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, n := range sizes {
+		doc := benchArray(n)
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			b.SetBytes(int64(len(doc)))
+			b.ReportAllocs()
+			for x := 0; x < b.N; x++ {
+				eval := mustEval(EvalPathsInBytes(doc, paths))
+				for {
+					if _, ok := eval.Next(); !ok {
+						break
+					}
+				}
+				if eval.Error != nil {
+					b.Fatal(eval.Error)
+				}
+			}
+		})
 	}
-	return vals
 }
+
+func BenchmarkDescendants(b *testing.B)  { benchmarkPath(b, `$..*+`, 1000, 4000, 16000) }
+func BenchmarkReverseSlice(b *testing.B) { benchmarkPath(b, `$.a[::-1].id+`, 1000, 4000, 16000) }
+func BenchmarkFilterPath(b *testing.B)   { benchmarkPath(b, `$.a[*]?(@.x == 2).id+`, 20000, 1000000) }
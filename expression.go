@@ -1,11 +1,16 @@
 package jsonpath
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
 	"reflect"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -28,39 +33,84 @@ func (e exprErrorBadTypeComparison) Error() string {
 	return fmt.Sprintf("Type %s cannot be compared to type %s", e.valueType, e.expectedType)
 }
 
-// Lowest priority = lowest #
-var opa = map[int]struct {
+type precedence map[int]struct {
 	prec   int
 	rAssoc bool
-}{
-	exprOpAnd:     {1, false},
+}
+
+// Lowest priority = lowest #
+var opa = precedence{
 	exprOpOr:      {1, false},
-	exprOpEq:      {2, false},
-	exprOpNeq:     {2, false},
-	exprOpLt:      {3, false},
-	exprOpLe:      {3, false},
-	exprOpGt:      {3, false},
-	exprOpGe:      {3, false},
-	exprOpPlus:    {4, false},
-	exprOpMinus:   {4, false},
-	exprOpSlash:   {5, false},
-	exprOpStar:    {5, false},
-	exprOpPercent: {5, false},
-	exprOpHat:     {6, false},
-	exprOpNot:     {7, true},
-	exprOpPlusUn:  {7, true},
-	exprOpMinusUn: {7, true},
+	exprOpAnd:     {2, false},
+	exprOpEq:      {3, false},
+	exprOpNeq:     {3, false},
+	exprOpLt:      {4, false},
+	exprOpLe:      {4, false},
+	exprOpGt:      {4, false},
+	exprOpGe:      {4, false},
+	exprOpMatch:   {4, false},
+	exprOpIn:      {4, false},
+	exprOpPlus:    {5, false},
+	exprOpMinus:   {5, false},
+	exprOpSlash:   {6, false},
+	exprOpStar:    {6, false},
+	exprOpPercent: {6, false},
+	exprOpHat:     {7, false},
+	exprOpNot:     {8, true},
+	exprOpPlusUn:  {8, true},
+	exprOpMinusUn: {8, true},
+
+	exprOpContains:   {4, false},
+	exprOpStartsWith: {4, false},
+	exprOpEndsWith:   {4, false},
+}
+
+// RFC 9535 binds && tighter than || and has no arithmetic
+var opaRFC9535 = precedence{
+	exprOpOr:  {1, false},
+	exprOpAnd: {2, false},
+	exprOpEq:  {3, false},
+	exprOpNeq: {3, false},
+	exprOpLt:  {3, false},
+	exprOpLe:  {3, false},
+	exprOpGt:  {3, false},
+	exprOpGe:  {3, false},
+	exprOpNot: {4, true},
+
+	// Jayway only
+	exprOpMatch: {3, false},
+	exprOpIn:    {3, false},
+}
+
+func infixToPostFix(items []Item) (out []Item, err error) {
+	return infixToPostFixPrecedence(items, opa)
 }
 
 // Shunting-yard Algorithm (infix -> postfix)
 // http://rosettacode.org/wiki/Parsing/Shunting-yard_algorithm#Go
-func infixToPostFix(items []Item) (out []Item, err error) {
+func infixToPostFixPrecedence(items []Item, opa precedence) (out []Item, err error) {
 	stack := newStack()
 
 	for _, i := range items {
 		switch i.typ {
 		case exprParenLeft:
 			stack.push(i) // push "(" to stack
+		case exprFunc:
+			// the call follows its arguments, which start at the marker
+			stack.push(i)
+			out = append(out, Item{typ: exprFuncStart, pos: i.pos})
+		case exprComma:
+			for {
+				op_interface, ok := stack.peek()
+				if !ok || op_interface.(Item).typ == exprParenLeft {
+					return nil, errors.New(exprErrorMismatchedParens)
+				}
+				if op_interface.(Item).typ == exprFunc {
+					break
+				}
+				stack.pop()
+				out = append(out, op_interface.(Item))
+			}
 		case exprParenRight:
 			found := false
 			for {
@@ -74,7 +124,11 @@ func infixToPostFix(items []Item) (out []Item, err error) {
 					found = true
 					break // discard "("
 				}
-				out = append(out, op) // add operator to result
+				out = append(out, op) // add operator or function call to result
+				if op.typ == exprFunc {
+					found = true
+					break
+				}
 			}
 			if !found {
 				return nil, errors.New(exprErrorMismatchedParens)
@@ -105,7 +159,7 @@ func infixToPostFix(items []Item) (out []Item, err error) {
 	for stack.len() > 0 {
 		op_int, _ := stack.pop()
 		op := op_int.(Item)
-		if op.typ == exprParenLeft {
+		if op.typ == exprParenLeft || op.typ == exprFunc {
 			return nil, errors.New(exprErrorMismatchedParens)
 		}
 		out = append(out, op)
@@ -113,317 +167,615 @@ func infixToPostFix(items []Item) (out []Item, err error) {
 	return
 }
 
-func evaluatePostFix(postFixItems []Item, pathValues map[string]Item) (interface{}, error) {
-	s := newStack()
-
-	if len(postFixItems) == 0 {
-		return false, errors.New(exprErrorBadExpression)
-	}
-
-	for _, item := range postFixItems {
+// walkPostFix calls visit for every operator and function call of a postfix
+// expression with the positions of the items that produce its operands. It
+// returns the position of the item that produces the result.
+func walkPostFix(postFix []Item, visit func(x int, operands []int) error) (int, error) {
+	stack := newIntStack()
+	for x, item := range postFix {
+		n := 0
 		switch item.typ {
-
-		// VALUES
-		case exprBool:
-			val, err := strconv.ParseBool(string(item.val))
-			if err != nil {
-				return false, fmt.Errorf(exprErrorBadValue, string(item.val), exprTokenNames[exprBool])
-			}
-			s.push(val)
-		case exprNumber:
-			val, err := strconv.ParseFloat(string(item.val), 64)
-			if err != nil {
-				return false, fmt.Errorf(exprErrorBadValue, string(item.val), exprTokenNames[exprNumber])
-			}
-			s.push(val)
-		case exprPath:
-			// TODO: Handle datatypes of JSON
-			i, ok := pathValues[string(item.val)]
-			if !ok {
-				return false, fmt.Errorf(exprErrorValueNotFound, string(item.val))
-			}
-			switch i.typ {
-			case jsonNull:
-				s.push(nil)
-			case jsonNumber:
-				val_float, err := strconv.ParseFloat(string(i.val), 64)
-				if err != nil {
-					return false, fmt.Errorf(exprErrorBadValue, string(item.val), jsonTokenNames[jsonNumber])
-				}
-				s.push(val_float)
-			case jsonKey, jsonString:
-				s.push(i.val)
-			default:
-				return false, fmt.Errorf(exprErrorPathValueNotScalar)
-			}
-		case exprString:
-			s.push(item.val)
-		case exprNull:
-			s.push(nil)
-
-		// OPERATORS
-		case exprOpAnd:
-			a, b, err := take2Bool(s, item.typ)
-			if err != nil {
-				return false, err
-			}
-
-			s.push(a && b)
-		case exprOpEq:
-			p, ok := s.peek()
-			if !ok {
-				return false, fmt.Errorf(exprErrorNotEnoughOperands, exprTokenNames[item.typ])
-			}
-			switch p.(type) {
-			case nil:
-				err := take2Null(s, item.typ)
-				if err != nil {
-					return false, err
-				} else {
-					s.push(true)
-				}
-			case bool:
-				a, b, err := take2Bool(s, item.typ)
-				if err != nil {
-					return false, err
-				}
-				s.push(a == b)
-			case float64:
-				a, b, err := take2Float(s, item.typ)
-				if err != nil {
-					return false, err
+		case exprFuncStart:
+			stack.push(-1)
+			continue
+		case exprFunc:
+			args := make([]int, 0, 2)
+			for {
+				o, ok := stack.pop()
+				if !ok {
+					return -1, errors.New(exprErrorMismatchedParens)
 				}
-				s.push(a == b)
-			case []byte:
-				a, b, err := take2ByteSlice(s, item.typ)
-				if err != nil {
-					return false, err
+				if o == -1 {
+					break
 				}
-				s.push(byteSlicesEqual(a, b))
+				args = append([]int{o}, args...)
 			}
-		case exprOpNeq:
-			p, ok := s.peek()
-			if !ok {
-				return false, fmt.Errorf(exprErrorNotEnoughOperands, exprTokenNames[item.typ])
+			if err := visit(x, args); err != nil {
+				return -1, err
 			}
-			switch p.(type) {
-			case nil:
-				err := take2Null(s, item.typ)
-				if err != nil {
-					return true, err
-				} else {
-					s.push(false)
-				}
-			case bool:
-				a, b, err := take2Bool(s, item.typ)
-				if err != nil {
-					return false, err
-				}
-				s.push(a != b)
-			case float64:
-				a, b, err := take2Float(s, item.typ)
-				if err != nil {
-					return false, err
-				}
-				s.push(a != b)
-			case []byte:
-				a, b, err := take2ByteSlice(s, item.typ)
-				if err != nil {
-					return false, err
-				}
-				s.push(!byteSlicesEqual(a, b))
-			}
-		case exprOpNot:
-			a, err := take1Bool(s, item.typ)
-			if err != nil {
-				return false, err
+			stack.push(x)
+			continue
+		case exprOpNot, exprOpPlusUn, exprOpMinusUn, exprOpExclam:
+			n = 1
+		default:
+			if _, isOp := opa[item.typ]; isOp {
+				n = 2
 			}
+		}
+		if n == 0 {
+			stack.push(x)
+			continue
+		}
 
-			s.push(!a)
-		case exprOpOr:
-			a, b, err := take2Bool(s, item.typ)
-			if err != nil {
-				return false, err
+		operands := make([]int, n)
+		for k := n - 1; k >= 0; k-- {
+			o, ok := stack.pop()
+			if !ok || o == -1 {
+				return -1, fmt.Errorf(exprErrorNotEnoughOperands, exprTokenNames[item.typ])
 			}
+			operands[k] = o
+		}
+		if err := visit(x, operands); err != nil {
+			return -1, err
+		}
+		stack.push(x)
+	}
+	if stack.len() != 1 {
+		return -1, errors.New(exprErrorBadExpression)
+	}
+	root, _ := stack.pop()
+	return root, nil
+}
 
-			s.push(a || b)
-		case exprOpGt:
-			a, b, err := take2Float(s, item.typ)
-			if err != nil {
-				return false, err
-			}
+// shortCircuit reports whether the left operand of && or || decides the
+// result on its own, so the right operand is not evaluated
+func shortCircuit(op int, left interface{}) bool {
+	b, ok := left.(bool)
+	return ok && b == (op == exprOpOr)
+}
 
-			s.push(b > a)
-		case exprOpGe:
-			a, b, err := take2Float(s, item.typ)
-			if err != nil {
-				return false, err
+// literalValue returns the value of a literal. Regular expressions and lists
+// are taken from op when it has them cached.
+func literalValue(item Item, dialect int, op *operator) (interface{}, error) {
+	switch item.typ {
+	case exprRegex:
+		return op.regexp(item.val)
+	case exprList:
+		return op.list(item.val, dialect)
+	case exprBool:
+		val, err := strconv.ParseBool(string(item.val))
+		if err != nil {
+			return false, fmt.Errorf(exprErrorBadValue, string(item.val), exprTokenNames[exprBool])
+		}
+		return val, nil
+	case exprNumber:
+		if op.exactNumbers() {
+			val, ok := new(big.Rat).SetString(string(item.val))
+			if !ok {
+				return false, fmt.Errorf(exprErrorBadValue, string(item.val), exprTokenNames[exprNumber])
 			}
+			return val, nil
+		}
+		val, err := strconv.ParseFloat(string(item.val), 64)
+		if err != nil {
+			return false, fmt.Errorf(exprErrorBadValue, string(item.val), exprTokenNames[exprNumber])
+		}
+		return val, nil
+	case exprString:
+		if dialect != dialectDefault {
+			return decodeRFC9535Value(Item{typ: jsonString, val: item.val})
+		}
+		return item.val, nil
+	}
+	return nil, nil
+}
 
-			s.push(b >= a)
-		case exprOpLt:
-			a, b, err := take2Float(s, item.typ)
-			if err != nil {
-				return false, err
-			}
+// operandValue returns the value of a path operand. A missing value is an error
+// in the default dialect and nothing in the others.
+func operandValue(item Item, pathValues map[string]Item, dialect int, exact bool) (interface{}, error) {
+	i, ok := pathValues[string(item.val)]
+	if ok && exact {
+		if val, isExact, err := decodeExactValue(i); isExact {
+			return val, err
+		}
+	}
+	if dialect != dialectDefault {
+		if !ok {
+			return nothing{}, nil
+		}
+		return decodeRFC9535Value(i)
+	}
 
-			s.push(b < a)
-		case exprOpLe:
-			a, b, err := take2Float(s, item.typ)
-			if err != nil {
-				return false, err
-			}
+	// TODO: Handle datatypes of JSON
+	if !ok {
+		return false, fmt.Errorf(exprErrorValueNotFound, string(item.val))
+	}
+	switch i.typ {
+	case jsonNull:
+		return nil, nil
+	case jsonNumber:
+		val_float, err := strconv.ParseFloat(string(i.val), 64)
+		if err != nil {
+			return false, fmt.Errorf(exprErrorBadValue, string(item.val), jsonTokenNames[jsonNumber])
+		}
+		return val_float, nil
+	case jsonKey, jsonString:
+		return i.val, nil
+	case jsonBool:
+		return i.val[0] == 't', nil
+	case jsonBraceLeft, jsonBracketLeft:
+		return jsonComposite(i.val), nil
+	}
+	return false, fmt.Errorf(exprErrorPathValueNotScalar)
+}
 
-			s.push(b <= a)
-		case exprOpPlus:
-			a, b, err := take2Float(s, item.typ)
-			if err != nil {
-				return false, err
-			}
-			s.push(b + a)
-		case exprOpPlusUn:
-			a, err := take1Float(s, item.typ)
-			if err != nil {
-				return false, err
-			}
-			s.push(a)
-		case exprOpMinus:
-			a, b, err := take2Float(s, item.typ)
-			if err != nil {
-				return false, err
-			}
-			s.push(b - a)
-		case exprOpMinusUn:
-			a, err := take1Float(s, item.typ)
-			if err != nil {
-				return false, err
-			}
-			s.push(0 - a)
-		case exprOpSlash:
-			a, b, err := take2Float(s, item.typ)
-			if err != nil {
-				return false, err
-			}
+func pathExists(item Item, pathValues map[string]Item, dialect int) bool {
+	i, ok := pathValues[string(item.val)]
+	if ok && dialect == dialectDefault && i.typ == jsonBool {
+		// bool members test their value, as they always have
+		ok = i.val[0] == 't'
+	}
+	return ok
+}
 
-			if a == 0.0 {
-				return false, errors.New("Cannot divide by zero")
-			}
-			s.push(b / a)
-		case exprOpStar:
-			a, b, err := take2Float(s, item.typ)
-			if err != nil {
-				return false, err
-			}
+// functionValue returns a path value passed to a function
+func functionValue(item Item, pathValues map[string]Item) (interface{}, error) {
+	if i, ok := pathValues[string(item.val)]; ok {
+		return decodeFunctionValue(i)
+	}
+	return Nothing, nil
+}
 
-			s.push(b * a)
-		case exprOpPercent:
-			a, b, err := take2Float(s, item.typ)
-			if err != nil {
-				return false, err
-			}
+// functionNodes returns the values of a path passed to a function as nodes
+func functionNodes(item Item, pathValues map[string]Item, pathNodes map[string][]Item) (nodeList, error) {
+	nodes, ok := pathNodes[string(item.val)]
+	if !ok {
+		if i, found := pathValues[string(item.val)]; found {
+			nodes = []Item{i}
+		}
+	}
+	values := make(nodeList, len(nodes))
+	for x, i := range nodes {
+		var err error
+		if values[x], err = decodeFunctionValue(i); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
 
-			s.push(math.Mod(b, a))
-		case exprOpHat:
-			a, b, err := take2Float(s, item.typ)
-			if err != nil {
-				return false, err
+func callFunction(f *filterFunction, args []interface{}, dialect int) (interface{}, error) {
+	if len(args) != len(f.signature.Params) {
+		return false, fmt.Errorf("Function %s() takes %d arguments, not %d", f.name, len(f.signature.Params), len(args))
+	}
+	return f.call(args, dialect)
+}
+
+// applyUnary applies !, + or - to a
+func applyUnary(op int, a interface{}) (interface{}, error) {
+	switch op {
+	case exprOpNot, exprOpExclam:
+		v, err := asBool(a)
+		if err != nil {
+			return false, err
+		}
+		return !v, nil
+	case exprOpPlusUn:
+		if r, ok := a.(*big.Rat); ok {
+			return r, nil
+		}
+		return asFloat(a)
+	}
+	if r, ok := a.(*big.Rat); ok {
+		return new(big.Rat).Neg(r), nil
+	}
+	v, err := asFloat(a)
+	if err != nil {
+		return false, err
+	}
+	return 0 - v, nil
+}
+
+// missingOperandError reports a binary operation with too few operands. A
+// right operand of the wrong type is reported first.
+func missingOperandError(op int, b interface{}, hasB bool, dialect int) error {
+	if hasB {
+		if _, err := applyBinary(op, b, b, dialect); err != nil {
+			if _, isType := err.(exprErrorBadTypeComparison); isType {
+				return err
 			}
+		}
+	}
+	return fmt.Errorf(exprErrorNotEnoughOperands, exprTokenNames[op])
+}
 
-			s.push(math.Pow(b, a))
-		case exprOpExclam:
-			a, err := take1Bool(s, item.typ)
+// applyBinary applies an operator to its left operand a and right operand b.
+// Type errors report the right operand first.
+func applyBinary(op int, a, b interface{}, dialect int) (interface{}, error) {
+	switch op {
+	case exprOpMatch, exprOpIn, exprOpContains, exprOpStartsWith, exprOpEndsWith:
+		return evaluateStringOp(op, a, b, dialect)
+	case exprOpAnd, exprOpOr:
+		vb, errB := asBool(b)
+		va, errA := asBool(a)
+		if err := firstError(errB, errA); err != nil {
+			return false, err
+		}
+		if op == exprOpAnd {
+			return va && vb, nil
+		}
+		return va || vb, nil
+	}
+
+	if ra, rb, ok := exactOperands(a, b); ok {
+		return applyExact(op, ra, rb)
+	}
+
+	switch op {
+	case exprOpEq, exprOpNeq, exprOpLt, exprOpLe, exprOpGt, exprOpGe:
+		if dialect != dialectDefault {
+			// Comparisons never fail in RFC 9535: values of different types
+			// are simply neither equal nor ordered
+			return compareRFC9535(op, a, b), nil
+		}
+	}
+
+	switch op {
+	case exprOpEq, exprOpNeq:
+		return equalStrict(a, b) == (op == exprOpEq), nil
+	case exprOpLt, exprOpLe, exprOpGt, exprOpGe:
+		if isByteSlice(b) {
+			sa, sb, err := asStrings(op, a, b)
 			if err != nil {
 				return false, err
 			}
-			s.push(!a)
-		// Other
-		default:
-			return false, fmt.Errorf("Token not supported in evaluator: %v", exprTokenNames[item.typ])
+			return compareOrdered(op, sa < sb, sa == sb), nil
+		}
+		fa, fb, err := asFloats(a, b)
+		if err != nil {
+			return false, err
 		}
+		return compareOrdered(op, fa < fb, fa == fb), nil
 	}
 
-	if s.len() != 1 {
-		return false, fmt.Errorf(exprErrorBadExpression)
+	fa, fb, err := asFloats(a, b)
+	if err != nil {
+		return false, err
 	}
-	end_int, _ := s.pop()
-	return end_int, nil
+	f, err := applyFloat(op, fa, fb)
+	if err != nil {
+		return false, err
+	}
+	return f, nil
 }
 
-func take1Bool(s *stack, op int) (bool, error) {
-	t := exprBool
-	val, ok := s.pop()
-	if !ok {
-		return false, fmt.Errorf(exprErrorNotEnoughOperands, exprTokenNames[op])
+// applyFloat applies an arithmetic operator to two numbers
+func applyFloat(op int, fa, fb float64) (float64, error) {
+	switch op {
+	case exprOpPlus:
+		return fa + fb, nil
+	case exprOpMinus:
+		return fa - fb, nil
+	case exprOpSlash:
+		if fb == 0.0 {
+			return 0, errors.New("Cannot divide by zero")
+		}
+		return fa / fb, nil
+	case exprOpStar:
+		return fa * fb, nil
+	case exprOpPercent:
+		return math.Mod(fa, fb), nil
+	case exprOpHat:
+		return math.Pow(fa, fb), nil
 	}
+	return 0, fmt.Errorf("Token not supported in evaluator: %v", exprTokenNames[op])
+}
 
-	b, ok := val.(bool)
-	if !ok {
-		return false, exprErrorBadTypeComparison{exprTokenNames[t], (reflect.TypeOf(val)).String()}
+// compareFloats applies a comparison to two numbers
+func compareFloats(op int, fa, fb float64) bool {
+	switch op {
+	case exprOpEq:
+		return fa == fb
+	case exprOpNeq:
+		return fa != fb
 	}
-	return b, nil
+	return compareOrdered(op, fa < fb, fa == fb)
 }
 
-func take2Bool(s *stack, op int) (bool, bool, error) {
-	a, a_err := take1Bool(s, op)
-	b, b_err := take1Bool(s, op)
-	return a, b, firstError(a_err, b_err)
+func compareOrdered(op int, less, equal bool) bool {
+	switch op {
+	case exprOpLt:
+		return less
+	case exprOpLe:
+		return less || equal
+	case exprOpGt:
+		return !less && !equal
+	}
+	return !less
 }
 
-func take1Float(s *stack, op int) (float64, error) {
-	t := exprNumber
-	val, ok := s.pop()
-	if !ok {
-		return 0.0, fmt.Errorf(exprErrorNotEnoughOperands, exprTokenNames[op])
+// equalStrict compares values of the default dialect. Values of different
+// types are never equal, so comparing with null tests for it.
+func equalStrict(a, b interface{}) bool {
+	switch vb := b.(type) {
+	case nil:
+		return a == nil
+	case nothing:
+		_, ok := a.(nothing)
+		return ok
+	case bool:
+		va, ok := a.(bool)
+		return ok && va == vb
+	case float64:
+		va, err := asFloat(a)
+		return err == nil && va == vb
+	case []byte:
+		va, ok := a.([]byte)
+		return ok && byteSlicesEqual(va, vb)
+	case jsonComposite, exactComposite, []interface{}:
+		va, okA := compositeValue(a)
+		cb, okB := compositeValue(b)
+		return okA && okB && reflect.DeepEqual(va, cb)
 	}
+	// a *big.Rat b is only left if a is not a number
+	return false
+}
 
-	b, ok := val.(float64)
+func asBool(val interface{}) (bool, error) {
+	b, ok := val.(bool)
 	if !ok {
-		return 0.0, exprErrorBadTypeComparison{exprTokenNames[t], (reflect.TypeOf(val)).String()}
+		return false, exprErrorBadTypeComparison{exprTokenNames[exprBool], fmt.Sprintf("%T", val)}
 	}
 	return b, nil
 }
 
-func take2Float(s *stack, op int) (float64, float64, error) {
-	a, a_err := take1Float(s, op)
-	b, b_err := take1Float(s, op)
-	return a, b, firstError(a_err, b_err)
-}
-
-func take1ByteSlice(s *stack, op int) ([]byte, error) {
-	t := exprNumber
-	val, ok := s.pop()
+func asFloat(val interface{}) (float64, error) {
+	if r, ok := val.(*big.Rat); ok {
+		f, _ := r.Float64()
+		return f, nil
+	}
+	f, ok := val.(float64)
 	if !ok {
-		return nil, fmt.Errorf(exprErrorNotEnoughOperands, exprTokenNames[op])
+		return 0.0, exprErrorBadTypeComparison{exprTokenNames[exprNumber], fmt.Sprintf("%T", val)}
 	}
+	return f, nil
+}
 
+func asFloats(a, b interface{}) (float64, float64, error) {
+	fb, errB := asFloat(b)
+	fa, errA := asFloat(a)
+	return fa, fb, firstError(errB, errA)
+}
+
+func asByteSlice(val interface{}) ([]byte, error) {
 	b, ok := val.([]byte)
 	if !ok {
-		return nil, exprErrorBadTypeComparison{exprTokenNames[t], (reflect.TypeOf(val)).String()}
+		return nil, exprErrorBadTypeComparison{exprTokenNames[exprNumber], fmt.Sprintf("%T", val)}
 	}
 	return b, nil
 }
 
-func take2ByteSlice(s *stack, op int) ([]byte, []byte, error) {
-	a, a_err := take1ByteSlice(s, op)
-	b, b_err := take1ByteSlice(s, op)
-	return a, b, firstError(a_err, b_err)
+func isByteSlice(val interface{}) bool {
+	_, ok := val.([]byte)
+	return ok
 }
 
-func take1Null(s *stack, op int) error {
-	t := exprNull
-	val, ok := s.pop()
-	if !ok {
-		return fmt.Errorf(exprErrorNotEnoughOperands, exprTokenNames[op])
+// asStrings decodes two JSON strings for ordering
+func asStrings(op int, a, b interface{}) (string, string, error) {
+	bb, errB := asByteSlice(b)
+	ba, errA := asByteSlice(a)
+	if err := firstError(errB, errA); err != nil {
+		return "", "", err
 	}
+	sa, okA := filterString(ba)
+	sb, okB := filterString(bb)
+	if !okA || !okB {
+		return "", "", fmt.Errorf(exprErrorBadOperandType, exprTokenNames[exprString], exprTokenNames[op])
+	}
+	return sa, sb, nil
+}
 
+func asNull(val interface{}) error {
 	if v := reflect.TypeOf(val); v != nil {
-		return exprErrorBadTypeComparison{exprTokenNames[t], v.String()}
+		return exprErrorBadTypeComparison{exprTokenNames[exprNull], v.String()}
 	}
 	return nil
 }
 
-func take2Null(s *stack, op int) error {
-	a_err := take1Null(s, op)
-	b_err := take1Null(s, op)
-	return firstError(a_err, b_err)
+// nothing is the value of a path that does not exist in RFC 9535 filters
+type nothing struct{}
+
+// jsonComposite holds an object or array value in RFC 9535 filters
+type jsonComposite []byte
+
+func decodeRFC9535Value(i Item) (interface{}, error) {
+	switch i.typ {
+	case jsonNull:
+		return nil, nil
+	case jsonBool:
+		return i.val[0] == 't', nil
+	case jsonNumber:
+		v, err := strconv.ParseFloat(string(i.val), 64)
+		if err != nil {
+			return nil, fmt.Errorf(exprErrorBadValue, string(i.val), jsonTokenNames[jsonNumber])
+		}
+		return v, nil
+	case jsonString, jsonKey:
+		var v string
+		if err := json.Unmarshal(i.val, &v); err != nil {
+			return nil, fmt.Errorf(exprErrorBadValue, string(i.val), jsonTokenNames[jsonString])
+		}
+		return v, nil
+	}
+	return jsonComposite(i.val), nil
+}
+
+func compareRFC9535(op int, a, b interface{}) bool {
+	switch op {
+	case exprOpEq:
+		return equalRFC9535(a, b)
+	case exprOpNeq:
+		return !equalRFC9535(a, b)
+	case exprOpLt:
+		return lessRFC9535(a, b)
+	case exprOpLe:
+		return lessRFC9535(a, b) || equalRFC9535(a, b)
+	case exprOpGt:
+		return lessRFC9535(b, a)
+	case exprOpGe:
+		return lessRFC9535(b, a) || equalRFC9535(a, b)
+	}
+	return false
+}
+
+func equalRFC9535(a, b interface{}) bool {
+	if isComposite(a) || isComposite(b) {
+		va, okA := compositeValue(a)
+		vb, okB := compositeValue(b)
+		return okA && okB && reflect.DeepEqual(va, vb)
+	}
+	if ra, rb, ok := exactOperands(a, b); ok {
+		return ra.Cmp(rb) == 0
+	}
+	return a == b
+}
+
+func isComposite(val interface{}) bool {
+	switch val.(type) {
+	case jsonComposite, exactComposite, []interface{}:
+		return true
+	}
+	return false
+}
+
+// compositeValue decodes an object or array, or the values of a list
+// literal, so they compare by value
+func compositeValue(val interface{}) (interface{}, bool) {
+	switch v := val.(type) {
+	case jsonComposite:
+		var d interface{}
+		if json.Unmarshal(v, &d) != nil {
+			return nil, false
+		}
+		return d, true
+	case exactComposite:
+		return decodeExactComposite(v)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for x, e := range v {
+			switch ev := e.(type) {
+			case []byte:
+				s, ok := filterString(ev)
+				if !ok {
+					return nil, false
+				}
+				e = s
+			case *big.Rat:
+				e = json.Number(ev.RatString())
+			}
+			list[x] = e
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+func lessRFC9535(a, b interface{}) bool {
+	switch va := a.(type) {
+	case float64:
+		vb, ok := b.(float64)
+		return ok && va < vb
+	case string:
+		vb, ok := b.(string)
+		return ok && va < vb
+	}
+	return false
+}
+
+// filterString returns the text of a string operand, which is a JSON string
+// in the default dialect and decoded in the others
+func filterString(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case string:
+		return v, true
+	case []byte:
+		if len(v) < 2 || (v[0] != '"' && v[0] != '\'') {
+			return "", false
+		}
+		if bytes.IndexByte(v, '\\') < 0 {
+			return string(v[1 : len(v)-1]), true
+		}
+		s, err := unquoteString(v)
+		return s, err == nil
+	}
+	return "", false
+}
+
+// evaluateStringOp applies =~, in, contains, startsWith or endsWith to a and
+// b. Operands of the wrong type are an error in the default dialect and
+// simply do not match in the others.
+func evaluateStringOp(op int, a, b interface{}, dialect int) (bool, error) {
+	if op == exprOpIn {
+		list, ok := b.([]interface{})
+		if !ok {
+			return false, fmt.Errorf(exprErrorBadOperandType, exprTokenNames[exprList], exprTokenNames[op])
+		}
+		for _, v := range list {
+			if dialect != dialectDefault && equalRFC9535(a, v) ||
+				dialect == dialectDefault && equalDefault(a, v) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	sa, ok := filterString(a)
+	if !ok {
+		if dialect != dialectDefault {
+			return false, nil
+		}
+		return false, exprErrorBadTypeComparison{exprTokenNames[exprString], fmt.Sprintf("%T", a)}
+	}
+	if op == exprOpMatch {
+		re, ok := b.(*regexp.Regexp)
+		if !ok {
+			return false, fmt.Errorf(exprErrorBadOperandType, exprTokenNames[exprRegex], exprTokenNames[op])
+		}
+		return re.MatchString(sa), nil
+	}
+
+	sb, ok := filterString(b)
+	if !ok {
+		if dialect != dialectDefault {
+			return false, nil
+		}
+		return false, exprErrorBadTypeComparison{exprTokenNames[exprString], fmt.Sprintf("%T", b)}
+	}
+	switch op {
+	case exprOpContains:
+		return strings.Contains(sa, sb), nil
+	case exprOpStartsWith:
+		return strings.HasPrefix(sa, sb), nil
+	default:
+		return strings.HasSuffix(sa, sb), nil
+	}
+}
+
+// equalDefault compares values of the default dialect without failing on
+// mismatched types
+func equalDefault(a, b interface{}) bool {
+	if isComposite(a) || isComposite(b) {
+		return equalRFC9535(a, b)
+	}
+	if ba, ok := a.([]byte); ok {
+		bb, ok := b.([]byte)
+		return ok && byteSlicesEqual(ba, bb)
+	}
+	if isByteSlice(b) {
+		return false
+	}
+	if ra, rb, ok := exactOperands(a, b); ok {
+		return ra.Cmp(rb) == 0
+	}
+	return a == b
 }
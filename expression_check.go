@@ -0,0 +1,276 @@
+package jsonpath
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// exprType is what is known about the type of a filter operand before the
+// filter is evaluated
+type exprType int
+
+const (
+	typeUnknown exprType = iota // path values, known once evaluated
+	typeNumber
+	typeString
+	typeBool
+	typeNull
+	typeRegex
+	typeList
+	typeNodes
+)
+
+var exprTypeNames = map[exprType]string{
+	typeUnknown: "unknown",
+	typeNumber:  "number",
+	typeString:  "string",
+	typeBool:    "bool",
+	typeNull:    "null",
+	typeRegex:   "regex",
+	typeList:    "list",
+	typeNodes:   "nodes",
+}
+
+// itemType returns the type of an operand item
+func itemType(typ int) exprType {
+	switch typ {
+	case exprNumber:
+		return typeNumber
+	case exprString:
+		return typeString
+	case exprBool, exprPathExists:
+		return typeBool
+	case exprNull:
+		return typeNull
+	case exprRegex:
+		return typeRegex
+	case exprList:
+		return typeList
+	case exprPathNodes:
+		return typeNodes
+	}
+	return typeUnknown
+}
+
+// isLiteral reports whether an item is a literal value
+func isLiteral(typ int) bool {
+	switch typ {
+	case exprNumber, exprString, exprBool, exprNull, exprRegex, exprList:
+		return true
+	}
+	return false
+}
+
+// filterError annotates an error in a where clause with the position of the
+// item it is about
+func filterError(op *operator, item Item, dialect int, err error) error {
+	pos := int(item.pos)
+	if dialect == dialectDefault {
+		pos++ // the parentheses are stripped before lexing
+	}
+	return fmt.Errorf("%s at %d in filter %s", err.Error(), pos, op.whereClauseBytes)
+}
+
+// checkTypes infers the types of the operands of a default dialect where
+// clause, rejecting operations that would fail for every node
+func checkTypes(op *operator) error {
+	postFix := op.whereClause
+	types := make([]exprType, len(postFix))
+	for x, item := range postFix {
+		types[x] = itemType(item.typ)
+	}
+
+	want := func(x, o int, allowed ...exprType) error {
+		if types[o] == typeUnknown {
+			return nil
+		}
+		names := make([]string, len(allowed))
+		for k, t := range allowed {
+			if types[o] == t {
+				return nil
+			}
+			names[k] = exprTypeNames[t]
+		}
+		return filterError(op, postFix[x], dialectDefault,
+			fmt.Errorf(exprErrorBadOperandType, strings.Join(names, " or "), exprTokenNames[postFix[x].typ]))
+	}
+	wantSame := func(x int, operands []int) error {
+		a, b := types[operands[0]], types[operands[1]]
+		if a != typeUnknown && b != typeUnknown && a != b {
+			return filterError(op, postFix[x], dialectDefault, exprErrorBadTypeComparison{exprTypeNames[a], exprTypeNames[b]})
+		}
+		return nil
+	}
+
+	root, err := walkPostFix(postFix, func(x int, operands []int) error {
+		var allowed []exprType
+		result := typeBool
+		switch postFix[x].typ {
+		case exprFunc:
+			f, err := op.function(postFix[x].val)
+			if err != nil {
+				return err
+			}
+			for k, a := range operands {
+				switch f.signature.Params[k] {
+				case ValueType:
+					err = want(x, a, typeNumber, typeString, typeBool, typeNull, typeList)
+				case LogicalType:
+					err = want(x, a, typeBool)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			if f.signature.Result == ValueType {
+				types[x] = typeUnknown
+			} else {
+				types[x] = typeBool
+			}
+			return nil
+		case exprOpAnd, exprOpOr, exprOpNot, exprOpExclam:
+			allowed = []exprType{typeBool}
+		case exprOpEq, exprOpNeq:
+			// anything may be compared with null
+			if types[operands[0]] != typeNull && types[operands[1]] != typeNull {
+				if err := wantSame(x, operands); err != nil {
+					return err
+				}
+			}
+			allowed = []exprType{typeNumber, typeString, typeBool, typeNull, typeList}
+		case exprOpLt, exprOpLe, exprOpGt, exprOpGe:
+			if err := wantSame(x, operands); err != nil {
+				return err
+			}
+			allowed = []exprType{typeNumber, typeString}
+		case exprOpContains, exprOpStartsWith, exprOpEndsWith:
+			allowed = []exprType{typeString}
+		case exprOpMatch:
+			if err := want(x, operands[0], typeString); err != nil {
+				return err
+			}
+			if types[operands[1]] != typeRegex {
+				return filterError(op, postFix[x], dialectDefault,
+					fmt.Errorf(exprErrorBadOperandType, exprTypeNames[typeRegex], exprTokenNames[exprOpMatch]))
+			}
+			types[x] = typeBool
+			return nil
+		case exprOpIn:
+			if err := want(x, operands[0], typeNumber, typeString, typeBool, typeNull); err != nil {
+				return err
+			}
+			if types[operands[1]] != typeList {
+				return filterError(op, postFix[x], dialectDefault,
+					fmt.Errorf(exprErrorBadOperandType, exprTypeNames[typeList], exprTokenNames[exprOpIn]))
+			}
+			types[x] = typeBool
+			return nil
+		default:
+			// arithmetic
+			allowed = []exprType{typeNumber}
+			result = typeNumber
+		}
+		for _, o := range operands {
+			if err := want(x, o, allowed...); err != nil {
+				return err
+			}
+		}
+		types[x] = result
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if t := types[root]; t != typeUnknown && t != typeBool {
+		return filterError(op, postFix[root], dialectDefault, fmt.Errorf(exprErrorFinalValueNotBool, exprTypeNames[t]))
+	}
+	return nil
+}
+
+// foldConstants evaluates the operations whose operands are all literals
+// once, replacing them with their result
+func foldConstants(op *operator, dialect int) error {
+	postFix := op.whereClause
+	constant := make([]bool, len(postFix))
+	start := make([]int, len(postFix))
+	parent := make([]int, len(postFix))
+	for x, item := range postFix {
+		constant[x] = isLiteral(item.typ)
+		start[x] = x
+		parent[x] = -1
+	}
+
+	folds := 0
+	_, err := walkPostFix(postFix, func(x int, operands []int) error {
+		if len(operands) > 0 {
+			start[x] = start[operands[0]]
+		}
+		constant[x] = postFix[x].typ != exprFunc
+		for _, o := range operands {
+			parent[o] = x
+			constant[x] = constant[x] && constant[o]
+		}
+		if constant[x] {
+			folds++
+		}
+		return nil
+	})
+	if err != nil || folds == 0 {
+		return err
+	}
+
+	folded := make([]Item, 0, len(postFix))
+	for x := 0; x < len(postFix); x++ {
+		// find the largest constant operation starting here
+		end := -1
+		for y := x; y < len(postFix); y++ {
+			if start[y] == x && constant[y] && !isLiteral(postFix[y].typ) && (parent[y] == -1 || !constant[parent[y]]) {
+				end = y
+				break
+			}
+		}
+		if end < 0 {
+			folded = append(folded, postFix[x])
+			continue
+		}
+		filter, err := compileFilter(postFix[x:end+1], dialect, op)
+		var val interface{}
+		if err == nil {
+			val, err = filter(&filterEnv{})
+		}
+		if err != nil {
+			return filterError(op, postFix[end], dialect, err)
+		}
+		item, err := literalItem(val, dialect)
+		if err != nil {
+			return filterError(op, postFix[end], dialect, err)
+		}
+		item.pos = postFix[x].pos
+		folded = append(folded, item)
+		x = end
+	}
+	op.whereClause = folded
+	return nil
+}
+
+// literalItem returns the literal item for a value of the evaluation stack
+func literalItem(val interface{}, dialect int) (Item, error) {
+	switch v := val.(type) {
+	case nil:
+		return Item{typ: exprNull, val: []byte("null")}, nil
+	case bool:
+		return Item{typ: exprBool, val: []byte(strconv.FormatBool(v))}, nil
+	case float64:
+		return Item{typ: exprNumber, val: []byte(strconv.FormatFloat(v, 'g', -1, 64))}, nil
+	case *big.Rat:
+		return Item{typ: exprNumber, val: []byte(v.RatString())}, nil
+	case []byte:
+		return Item{typ: exprString, val: v}, nil
+	case string:
+		return Item{typ: exprString, val: quoteString(v)}, nil
+	}
+	return Item{}, errors.New(exprErrorBadExpression)
+}
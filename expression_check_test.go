@@ -0,0 +1,84 @@
+package jsonpath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTypes(t *testing.T) {
+	as := assert.New(t)
+
+	for _, path := range []string{
+		`$.a[*]?(@.b && @.c)`,
+		`$.a[*]?(!@.b || @.c > 3)`,
+		`$.a[*]?(@.b == "x" && @.c < @.d)`,
+		`$.a[*]?(@.b + 1 > 2 * @.c)`,
+		`$.a[*]?(@.b =~ /x/ && @.c in [1, 2])`,
+		`$.a[*]?(@.tags == ['a', 'b'])`,
+		`$.a[*]?(length(@.b) > 2)`,
+		`$.a[*]?(@.b)`,
+		`$.a[*]?(@.b != null && "x" != null)`,
+	} {
+		_, err := parsePath(path)
+		as.NoError(err, path)
+	}
+
+	for path, msg := range map[string]string{
+		`$.a[*]?(@.b && 3)`:                `Operand type expected to be "bool" for operation "&&" at 5 in filter (@.b && 3)`,
+		`$.a[*]?(@.b > 1 + "x")`:           `Operand type expected to be "number" for operation "+" at 9 in filter (@.b > 1 + "x")`,
+		`$.a[*]?("x" == 1)`:                `Type string cannot be compared to type number at 5 in filter ("x" == 1)`,
+		`$.a[*]?(@.b < true)`:              `Operand type expected to be "number or string" for operation "<" at 5 in filter (@.b < true)`,
+		`$.a[*]?(@.b contains 3)`:          `Operand type expected to be "string" for operation "contains" at 5 in filter (@.b contains 3)`,
+		`$.a[*]?(@.b =~ "x")`:              `Operand type expected to be "regex" for operation "=~" at 5 in filter (@.b =~ "x")`,
+		`$.a[*]?(@.b in "x")`:              `Operand type expected to be "list" for operation "in" at 5 in filter (@.b in "x")`,
+		`$.a[*]?(@.b + 1)`:                 `Expression evaluated to a non-bool: number at 5 in filter (@.b + 1)`,
+		`$.a[*]?(@.b > 1 / 0)`:             `Cannot divide by zero at 9 in filter (@.b > 1 / 0)`,
+		`$.a[*]?(match(@.b, "x") + 1 > 2)`: `Operand type expected to be "number" for operation "+" at 17 in filter (match(@.b, "x") + 1 > 2)`,
+	} {
+		_, err := parsePath(path)
+		if as.Error(err, path) {
+			as.EqualError(err, msg)
+		}
+	}
+}
+
+func TestFoldConstants(t *testing.T) {
+	as := assert.New(t)
+
+	for _, test := range []struct {
+		path     string
+		dialect  int
+		expected []Item
+	}{
+		{`$.a[*]?(1 + 2 * 3 > @.b)`, dialectDefault, []Item{
+			{typ: exprNumber, val: []byte(`7`)},
+			{typ: exprPath, val: []byte(`@.b`)},
+			{typ: exprOpGt, val: []byte(`>`)},
+		}},
+		{`$.a[*]?(@.b == -(2 ^ 3) || 'x' == "x")`, dialectDefault, []Item{
+			{typ: exprPath, val: []byte(`@.b`)},
+			{typ: exprNumber, val: []byte(`-8`)},
+			{typ: exprOpEq, val: []byte(`==`)},
+			{typ: exprBool, val: []byte(`true`)},
+			{typ: exprOpOr, val: []byte(`||`)},
+		}},
+		{`$.a[?@.b == 'x' && 1 < 2]`, dialectRFC9535, []Item{
+			{typ: exprPath, val: []byte(`@.b`)},
+			{typ: exprString, val: []byte(`"x"`)},
+			{typ: exprOpEq, val: []byte(`==`)},
+			{typ: exprBool, val: []byte(`true`)},
+			{typ: exprOpAnd, val: []byte(`&&`)},
+		}},
+	} {
+		path, err := genPath(test.path, test.dialect, false)
+		if !as.NoError(err, test.path) {
+			continue
+		}
+		clause := path.operators[len(path.operators)-1].whereClause
+		for x := range clause {
+			clause[x].pos, clause[x].line, clause[x].column = 0, 0, 0
+		}
+		as.EqualValues(test.expected, clause, test.path)
+	}
+}
@@ -0,0 +1,307 @@
+package jsonpath
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// compiledFilter evaluates a where clause compiled by compileFilter
+type compiledFilter func(env *filterEnv) (interface{}, error)
+
+// filterEnv holds the path values a compiled filter is evaluated with: the
+// first value of each path and all of them, if known
+type filterEnv struct {
+	values map[string]Item
+	nodes  map[string][]Item
+}
+
+// reset empties env for the next candidate, keeping its maps. The nodes are
+// only collected when nodes is set.
+func (env *filterEnv) reset(nodes bool) {
+	if env.values == nil {
+		env.values = make(map[string]Item)
+	}
+	for k := range env.values {
+		delete(env.values, k)
+	}
+	if !nodes {
+		env.nodes = nil
+		return
+	}
+	if env.nodes == nil {
+		env.nodes = make(map[string][]Item)
+	}
+	// the lists may belong to $ paths, so they are dropped instead of reused
+	for k := range env.nodes {
+		delete(env.nodes, k)
+	}
+}
+
+// compiledNode is an operand of a compiled filter. Operands that may be
+// numbers or strings also have typed closures, which return false when the
+// value has another type so the operator falls back to the generic closure.
+type compiledNode struct {
+	eval   compiledFilter
+	number func(env *filterEnv) (float64, bool)
+	str    func(env *filterEnv) ([]byte, bool)
+}
+
+// compileFilter turns a postfix where clause into a tree of closures once, so
+// literals are parsed and operators resolved before any node is evaluated.
+// Comparisons and arithmetic on numbers, and equality of strings, use typed
+// closures that neither box their operands nor switch on their types.
+func compileFilter(postFix []Item, dialect int, op *operator) (compiledFilter, error) {
+	if len(postFix) == 0 {
+		return nil, errors.New(exprErrorBadExpression)
+	}
+
+	exact := op.exactNumbers()
+	stack := make([]compiledNode, 0, len(postFix))
+	args := newIntStack() // where the arguments of each open function call begin
+	pop := func() (compiledNode, bool) {
+		if start, ok := args.peek(); len(stack) == 0 || ok && len(stack) == start {
+			return compiledNode{}, false
+		}
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return c, true
+	}
+
+	for _, item := range postFix {
+		item := item
+		var c compiledNode
+		switch item.typ {
+		case exprRegex, exprList, exprBool, exprNumber, exprString, exprNull:
+			val, err := literalValue(item, dialect, op)
+			if err != nil {
+				return nil, err
+			}
+			c = compileLiteral(val)
+		case exprPath:
+			c = compilePath(item, dialect, exact)
+		case exprPathExists:
+			c.eval = func(env *filterEnv) (interface{}, error) {
+				return pathExists(item, env.values, dialect), nil
+			}
+		case exprPathValue:
+			c.eval = func(env *filterEnv) (interface{}, error) {
+				return functionValue(item, env.values)
+			}
+		case exprPathNodes:
+			c.eval = func(env *filterEnv) (interface{}, error) {
+				return functionNodes(item, env.values, env.nodes)
+			}
+		case exprFuncStart:
+			args.push(len(stack))
+			continue
+		case exprFunc:
+			start, ok := args.pop()
+			if !ok {
+				return nil, errors.New(exprErrorMismatchedParens)
+			}
+			f, err := op.function(item.val)
+			if err != nil {
+				return nil, err
+			}
+			c.eval = compileCall(f, stack[start:], dialect)
+			stack = stack[:start]
+		case exprOpNot, exprOpExclam, exprOpPlusUn, exprOpMinusUn:
+			a, ok := pop()
+			if !ok {
+				return nil, fmt.Errorf(exprErrorNotEnoughOperands, exprTokenNames[item.typ])
+			}
+			c.eval = func(env *filterEnv) (interface{}, error) {
+				v, err := a.eval(env)
+				if err != nil {
+					return false, err
+				}
+				return applyUnary(item.typ, v)
+			}
+		default:
+			if _, isOp := opa[item.typ]; !isOp {
+				return nil, fmt.Errorf("Token not supported in evaluator: %v", exprTokenNames[item.typ])
+			}
+			b, okB := pop()
+			a, okA := pop()
+			switch {
+			case !okA || !okB:
+				c.eval = compileMissingOperand(item.typ, b.eval, okB, dialect)
+			case item.typ == exprOpAnd || item.typ == exprOpOr:
+				c.eval = compileLogical(item.typ, a.eval, b.eval, dialect)
+			default:
+				c = compileBinary(item.typ, a, b, dialect)
+			}
+		}
+		stack = append(stack, c)
+	}
+
+	if len(stack) != 1 || args.len() > 0 {
+		return nil, errors.New(exprErrorBadExpression)
+	}
+	return stack[0].eval, nil
+}
+
+func compileLiteral(val interface{}) compiledNode {
+	c := compiledNode{eval: func(*filterEnv) (interface{}, error) {
+		return val, nil
+	}}
+	switch v := val.(type) {
+	case float64:
+		c.number = func(*filterEnv) (float64, bool) {
+			return v, true
+		}
+	case []byte:
+		c.str = func(*filterEnv) ([]byte, bool) {
+			return v, true
+		}
+	}
+	return c
+}
+
+func compilePath(item Item, dialect int, exact bool) compiledNode {
+	c := compiledNode{eval: func(env *filterEnv) (interface{}, error) {
+		return operandValue(item, env.values, dialect, exact)
+	}}
+	if exact {
+		return c
+	}
+	key := string(item.val)
+	c.number = func(env *filterEnv) (float64, bool) {
+		i, ok := env.values[key]
+		if !ok || i.typ != jsonNumber {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(string(i.val), 64)
+		return f, err == nil
+	}
+	if dialect == dialectDefault {
+		// strings are compared as they are written in the default dialect
+		c.str = func(env *filterEnv) ([]byte, bool) {
+			i, ok := env.values[key]
+			if !ok || i.typ != jsonString && i.typ != jsonKey {
+				return nil, false
+			}
+			return i.val, true
+		}
+	}
+	return c
+}
+
+// compileBinary compiles an operator other than && and ||
+func compileBinary(op int, a, b compiledNode, dialect int) compiledNode {
+	generic := func(env *filterEnv) (interface{}, error) {
+		va, err := a.eval(env)
+		if err != nil {
+			return false, err
+		}
+		vb, err := b.eval(env)
+		if err != nil {
+			return false, err
+		}
+		return applyBinary(op, va, vb, dialect)
+	}
+
+	c := compiledNode{eval: generic}
+	switch op {
+	case exprOpEq, exprOpNeq, exprOpLt, exprOpLe, exprOpGt, exprOpGe:
+		numbers := a.number != nil && b.number != nil
+		strs := (op == exprOpEq || op == exprOpNeq) && a.str != nil && b.str != nil
+		if !numbers && !strs {
+			break
+		}
+		c.eval = func(env *filterEnv) (interface{}, error) {
+			if numbers {
+				if fa, ok := a.number(env); ok {
+					if fb, ok := b.number(env); ok {
+						return compareFloats(op, fa, fb), nil
+					}
+				}
+			}
+			if strs {
+				if sa, ok := a.str(env); ok {
+					if sb, ok := b.str(env); ok {
+						return byteSlicesEqual(sa, sb) == (op == exprOpEq), nil
+					}
+				}
+			}
+			return generic(env)
+		}
+	case exprOpPlus, exprOpMinus, exprOpStar, exprOpSlash, exprOpPercent, exprOpHat:
+		if a.number == nil || b.number == nil {
+			break
+		}
+		number := func(env *filterEnv) (float64, bool) {
+			fa, ok := a.number(env)
+			if !ok {
+				return 0, false
+			}
+			fb, ok := b.number(env)
+			if !ok {
+				return 0, false
+			}
+			f, err := applyFloat(op, fa, fb)
+			return f, err == nil
+		}
+		c.number = number
+		c.eval = func(env *filterEnv) (interface{}, error) {
+			if f, ok := number(env); ok {
+				return f, nil
+			}
+			return generic(env)
+		}
+	}
+	return c
+}
+
+func compileCall(f *filterFunction, argNodes []compiledNode, dialect int) compiledFilter {
+	argFilters := make([]compiledFilter, len(argNodes))
+	for x, a := range argNodes {
+		argFilters[x] = a.eval
+	}
+	return func(env *filterEnv) (interface{}, error) {
+		// the function converts its arguments in place
+		args := make([]interface{}, len(argFilters))
+		for x, a := range argFilters {
+			var err error
+			if args[x], err = a(env); err != nil {
+				return false, err
+			}
+		}
+		return callFunction(f, args, dialect)
+	}
+}
+
+// compileLogical compiles && or ||, which skip their right operand when the
+// left one decides the result
+func compileLogical(op int, a, b compiledFilter, dialect int) compiledFilter {
+	return func(env *filterEnv) (interface{}, error) {
+		va, err := a(env)
+		if err != nil {
+			return false, err
+		}
+		if shortCircuit(op, va) {
+			return va, nil
+		}
+		vb, err := b(env)
+		if err != nil {
+			return false, err
+		}
+		return applyBinary(op, va, vb, dialect)
+	}
+}
+
+// compileMissingOperand fails like an operation with too few operands does
+// when it is evaluated
+func compileMissingOperand(op int, b compiledFilter, hasB bool, dialect int) compiledFilter {
+	return func(env *filterEnv) (interface{}, error) {
+		if !hasB {
+			return false, missingOperandError(op, nil, false, dialect)
+		}
+		vb, err := b(env)
+		if err != nil {
+			return false, err
+		}
+		return false, missingOperandError(op, vb, true, dialect)
+	}
+}
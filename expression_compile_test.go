@@ -0,0 +1,88 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func compileTestFilter(input string) (compiledFilter, error) {
+	lexer := NewSliceLexer([]byte(input), EXPRESSION)
+	items := readerToArray(lexer)
+	// trim EOF
+	items = items[0 : len(items)-1]
+	postFix, err := infixToPostFix(items)
+	if err != nil {
+		return nil, err
+	}
+	return compileFilter(postFix, dialectDefault, nil)
+}
+
+func TestCompiledExpressions(t *testing.T) {
+	as := assert.New(t)
+
+	for _, test := range exprTests {
+		filter, err := compileTestFilter(test.input)
+		if !as.NoError(err, "Could not compile\nTest: %q", test.input) {
+			continue
+		}
+		val, err := filter(&filterEnv{values: test.fields})
+		if as.NoError(err, "Could not evaluate\nTest: %q", test.input) {
+			as.EqualValues(test.expectedValue, val, "Test: %q", test.input)
+		}
+	}
+}
+
+func TestBadCompiledExpressions(t *testing.T) {
+	as := assert.New(t)
+
+	for _, test := range exprErrorTests {
+		filter, err := compileTestFilter(test.input)
+		if err == nil {
+			_, err = filter(&filterEnv{values: test.fields})
+		}
+		if as.Error(err, "Test: %q", test.input) {
+			as.True(strings.Contains(err.Error(), test.expectedErrorSubstring), "Test: %q\nError %q does not contain %q", test.input, err.Error(), test.expectedErrorSubstring)
+		}
+	}
+}
+
+func TestCompiledTypedOperands(t *testing.T) {
+	as := assert.New(t)
+
+	values := map[string]Item{
+		"@.n": genValue(`2.5`, jsonNumber),
+		"@.m": genValue(`4`, jsonNumber),
+		"@.s": genValue(`"a"`, jsonString),
+		"@.t": genValue(`"b"`, jsonString),
+		"@.b": genValue(`true`, jsonBool),
+		"@.z": genValue(`null`, jsonNull),
+		"@.l": genValue(`[1]`, jsonBracketLeft),
+	}
+	operands := []string{`@.n`, `@.m`, `@.s`, `@.t`, `@.b`, `@.z`, `@.l`, `@.missing`, `3`, `"a"`, `@.n * 2`, `@.n / 0`, `@.s + 1`}
+	operators := []string{`==`, `!=`, `<`, `<=`, `>`, `>=`, `+`, `-`, `*`, `/`, `%`, `^`}
+
+	// the typed closures of the compiled filter fall back to the operators
+	// of the interpreter whenever an operand is not of their type
+	for _, a := range operands {
+		for _, op := range operators {
+			for _, b := range operands {
+				input := fmt.Sprintf("%s %s %s", a, op, b)
+				filter, err := compileTestFilter(input)
+				if !as.NoError(err, input) {
+					continue
+				}
+				lexer := NewSliceLexer([]byte(input), EXPRESSION)
+				items := readerToArray(lexer)
+				postFix, _ := infixToPostFix(items[0 : len(items)-1])
+
+				expected, expectedErr := evaluatePostFix(postFix, values)
+				actual, err := filter(&filterEnv{values: values})
+				as.Equal(expectedErr, err, input)
+				as.Equal(expected, actual, input)
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package jsonpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// largest exponent ^ raises exact numbers to without falling back to float64
+const maxExactExponent = 1024
+
+// exactComposite holds an object or array value in filters of paths with
+// exact numbers, so its numbers compare exactly too
+type exactComposite []byte
+
+// decodeExactValue returns a number or composite path value for exact
+// numbers. isExact is false for values that decode as usual.
+func decodeExactValue(i Item) (val interface{}, isExact bool, err error) {
+	switch i.typ {
+	case jsonNumber:
+		r, ok := new(big.Rat).SetString(string(i.val))
+		if !ok {
+			return false, true, fmt.Errorf(exprErrorBadValue, string(i.val), jsonTokenNames[jsonNumber])
+		}
+		return r, true, nil
+	case jsonBraceLeft, jsonBracketLeft:
+		return exactComposite(i.val), true, nil
+	}
+	return nil, false, nil
+}
+
+// decodeExactComposite decodes an object or array with its numbers as
+// json.Number in lowest terms, so 1.0 and 1 are equal
+func decodeExactComposite(val exactComposite) (interface{}, bool) {
+	d := json.NewDecoder(bytes.NewReader(val))
+	d.UseNumber()
+	var v interface{}
+	if d.Decode(&v) != nil {
+		return nil, false
+	}
+	return normalizeNumbers(v)
+}
+
+func normalizeNumbers(val interface{}) (interface{}, bool) {
+	switch v := val.(type) {
+	case json.Number:
+		r, ok := new(big.Rat).SetString(v.String())
+		if !ok {
+			return nil, false
+		}
+		return json.Number(r.RatString()), true
+	case []interface{}:
+		for x, e := range v {
+			n, ok := normalizeNumbers(e)
+			if !ok {
+				return nil, false
+			}
+			v[x] = n
+		}
+	case map[string]interface{}:
+		for k, e := range v {
+			n, ok := normalizeNumbers(e)
+			if !ok {
+				return nil, false
+			}
+			v[k] = n
+		}
+	}
+	return val, true
+}
+
+// exactOperands returns two numbers as *big.Rat when at least one of them is
+// exact
+func exactOperands(a, b interface{}) (*big.Rat, *big.Rat, bool) {
+	_, exactA := a.(*big.Rat)
+	_, exactB := b.(*big.Rat)
+	if !exactA && !exactB {
+		return nil, nil, false
+	}
+	ra, rb := toRat(a), toRat(b)
+	return ra, rb, ra != nil && rb != nil
+}
+
+func toRat(val interface{}) *big.Rat {
+	switch v := val.(type) {
+	case *big.Rat:
+		return v
+	case float64:
+		return new(big.Rat).SetFloat64(v) // nil for NaN and infinities
+	}
+	return nil
+}
+
+// applyExact applies a comparison or arithmetic operator to exact numbers
+func applyExact(op int, a, b *big.Rat) (interface{}, error) {
+	switch op {
+	case exprOpEq:
+		return a.Cmp(b) == 0, nil
+	case exprOpNeq:
+		return a.Cmp(b) != 0, nil
+	case exprOpLt, exprOpLe, exprOpGt, exprOpGe:
+		c := a.Cmp(b)
+		return compareOrdered(op, c < 0, c == 0), nil
+	case exprOpPlus:
+		return new(big.Rat).Add(a, b), nil
+	case exprOpMinus:
+		return new(big.Rat).Sub(a, b), nil
+	case exprOpStar:
+		return new(big.Rat).Mul(a, b), nil
+	case exprOpSlash, exprOpPercent:
+		if b.Sign() == 0 {
+			return false, errors.New("Cannot divide by zero")
+		}
+		q := new(big.Rat).Quo(a, b)
+		if op == exprOpSlash {
+			return q, nil
+		}
+		// the remainder has the sign of a, like math.Mod
+		t := new(big.Int).Quo(q.Num(), q.Denom())
+		return q.Sub(a, q.Mul(b, new(big.Rat).SetInt(t))), nil
+	case exprOpHat:
+		return exactPower(a, b)
+	}
+	return false, fmt.Errorf("Token not supported in evaluator: %v", exprTokenNames[op])
+}
+
+// exactPower raises a to b exactly for integer exponents, and as float64
+// otherwise
+func exactPower(a, b *big.Rat) (interface{}, error) {
+	if !b.IsInt() || b.Num().CmpAbs(big.NewInt(maxExactExponent)) > 0 {
+		fa, _ := a.Float64()
+		fb, _ := b.Float64()
+		return math.Pow(fa, fb), nil
+	}
+	n := b.Num().Int64()
+	if n < 0 {
+		if a.Sign() == 0 {
+			return false, errors.New("Cannot divide by zero")
+		}
+		a, n = new(big.Rat).Inv(a), -n
+	}
+	e := big.NewInt(n)
+	num := new(big.Int).Exp(a.Num(), e, nil)
+	denom := new(big.Int).Exp(a.Denom(), e, nil)
+	return new(big.Rat).SetFrac(num, denom), nil
+}
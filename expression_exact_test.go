@@ -0,0 +1,64 @@
+package jsonpath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExactNumbers(t *testing.T) {
+	as := assert.New(t)
+
+	doc := `{"items":[
+		{"id":9007199254740992,"price":0.3,"tags":[1.0,9007199254740993]},
+		{"id":9007199254740993,"price":0.1,"tags":[2]},
+		{"id":12345678901234567890,"price":1e-30,"tags":[]}
+	]}`
+	tests := []struct {
+		path    string
+		exact   []string
+		inexact []string
+		dialect int
+	}{
+		{`$.items[*]?(@.id == 9007199254740993).id+`, []string{`9007199254740993`}, []string{`9007199254740992`, `9007199254740993`}, dialectDefault},
+		{`$.items[*]?(@.id > 9007199254740992).id+`, []string{`9007199254740993`, `12345678901234567890`}, []string{`12345678901234567890`}, dialectDefault},
+		{`$.items[*]?(@.id + 1 == 9007199254740993).id+`, []string{`9007199254740992`}, []string{`9007199254740992`, `9007199254740993`}, dialectDefault},
+		{`$.items[*]?(@.price * 3 == 0.3).id+`, []string{`9007199254740993`}, []string{}, dialectDefault},
+		{`$.items[*]?(@.price == 0.1 + 0.2).id+`, []string{`9007199254740992`}, []string{}, dialectDefault},
+		{`$.items[*]?(@.price < 10 ^ -29).id+`, []string{`12345678901234567890`}, []string{`12345678901234567890`}, dialectDefault},
+		{`$.items[*]?(@.id % 10 == 3).id+`, []string{`9007199254740993`}, []string{}, dialectDefault},
+		{`$.items[*]?(@.id in [9007199254740993, 1]).id+`, []string{`9007199254740993`}, []string{`9007199254740992`, `9007199254740993`}, dialectDefault},
+		{`$.items[*]?(@.tags == [1, 9007199254740993]).id+`, []string{`9007199254740992`}, []string{`9007199254740992`}, dialectDefault},
+		{`$.items[*]?(@.tags == [1, 9007199254740992]).id+`, []string{}, []string{`9007199254740992`}, dialectDefault},
+		{`$.items[*]?(length(@.tags) == 2 && -@.price < 0).id+`, []string{`9007199254740992`}, []string{`9007199254740992`}, dialectDefault},
+		{`$.items[?@.id == 9007199254740993].id`, []string{`9007199254740993`}, []string{`9007199254740992`, `9007199254740993`}, dialectRFC9535},
+		{`$.items[?(@.id != 9007199254740992 && @.id < 1e19)].id`, []string{`9007199254740993`}, []string{}, dialectJayway},
+	}
+
+	for _, test := range tests {
+		for _, exact := range []bool{true, false} {
+			path, err := genPath(test.path, test.dialect, false)
+			if !as.NoError(err, test.path) {
+				continue
+			}
+			expected := test.inexact
+			if exact {
+				expected = test.exact
+				if !as.NoError(path.ExactNumbers(), test.path) {
+					continue
+				}
+			}
+			eval := mustEval(EvalPathsInBytes([]byte(doc), []*Path{path}))
+			actual := []string{}
+			for {
+				r, ok := eval.Next()
+				if !ok {
+					break
+				}
+				actual = append(actual, string(r.Value))
+			}
+			as.NoError(eval.Error, test.path)
+			as.Equal(expected, actual, "%s exact=%v", test.path, exact)
+		}
+	}
+}
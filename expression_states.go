@@ -1,5 +1,7 @@
 package jsonpath
 
+import "errors"
+
 const (
 	exprError = iota
 	exprEOF
@@ -10,6 +12,14 @@ const (
 	exprBool
 	exprNull
 	exprString
+	exprPathExists
+	exprRegex
+	exprList
+	exprPathValue // path whose value is a function argument
+	exprPathNodes // path whose nodes are a function argument
+	exprFunc      // function name and its opening parenthesis
+	exprFuncStart // marks the first argument of a function in postfix
+	exprComma
 
 	exprOperators
 	exprOpEq
@@ -30,6 +40,11 @@ const (
 	exprOpHat
 	exprOpPercent
 	exprOpExclam
+	exprOpMatch
+	exprOpIn
+	exprOpContains
+	exprOpStartsWith
+	exprOpEndsWith
 )
 
 var exprTokenNames = map[int]string{
@@ -43,6 +58,14 @@ var exprTokenNames = map[int]string{
 	exprBool:       "bool",
 	exprNull:       "null",
 	exprString:     "string",
+	exprPathExists: "exists",
+	exprRegex:      "regex",
+	exprList:       "list",
+	exprPathValue:  "value",
+	exprPathNodes:  "nodes",
+	exprFunc:       "function",
+	exprFuncStart:  "arguments",
+	exprComma:      ",",
 	exprOpEq:       "==",
 	exprOpNeq:      "!=",
 	exprOpNot:      "!",
@@ -61,6 +84,20 @@ var exprTokenNames = map[int]string{
 	exprOpHat:      "^",
 	exprOpPercent:  "%",
 	exprOpExclam:   "!",
+
+	exprOpMatch:      "=~",
+	exprOpIn:         "in",
+	exprOpContains:   "contains",
+	exprOpStartsWith: "startsWith",
+	exprOpEndsWith:   "endsWith",
+}
+
+// operators written as words, which follow a value like the symbols
+var exprWordOperators = map[string]int{
+	"in":         exprOpIn,
+	"contains":   exprOpContains,
+	"startsWith": exprOpStartsWith,
+	"endsWith":   exprOpEndsWith,
 }
 
 var EXPRESSION = lexExprText
@@ -76,7 +113,7 @@ func lexExprText(l lexer, state *intStack) stateFn {
 		l.emit(exprParenLeft)
 		next = lexExprText
 	case ')':
-		if top, ok := state.peek(); ok && top != exprParenLeft {
+		if top, ok := state.peek(); ok && top != exprParenLeft && top != exprFunc {
 			next = l.errorf("Received %#U but has no matching (", cur)
 			break
 		}
@@ -97,7 +134,7 @@ func lexExprText(l lexer, state *intStack) stateFn {
 		l.take()
 		l.emit(exprOpMinusUn)
 		next = lexExprText
-	case '@': //, '$': // Only support current key location
+	case '@', '$':
 		l.take()
 		takePath(l)
 		l.emit(exprPath)
@@ -106,18 +143,6 @@ func lexExprText(l lexer, state *intStack) stateFn {
 		takeNumeric(l)
 		l.emit(exprNumber)
 		next = lexOneValue
-	case 't':
-		takeExactSequence(l, bytesTrue)
-		l.emit(exprBool)
-		next = lexOneValue
-	case 'f':
-		takeExactSequence(l, bytesFalse)
-		l.emit(exprBool)
-		next = lexOneValue
-	case 'n':
-		takeExactSequence(l, bytesNull)
-		l.emit(exprNull)
-		next = lexOneValue
 	case '"':
 		err := l.takeString()
 		if err != nil {
@@ -125,11 +150,47 @@ func lexExprText(l lexer, state *intStack) stateFn {
 		}
 		l.emit(exprString)
 		next = lexOneValue
+	case '\'':
+		err := takeQuoted(l)
+		if err != nil {
+			return l.errorf("Could not take string because %q", err)
+		}
+		l.emit(exprString)
+		next = lexOneValue
+	case '/':
+		if err := takeRegex(l); err != nil {
+			return l.errorf("%s", err)
+		}
+		l.emit(exprRegex)
+		next = lexOneValue
+	case '[':
+		if err := takeList(l); err != nil {
+			return l.errorf("%s", err)
+		}
+		l.emit(exprList)
+		next = lexOneValue
 	case eof:
 		l.emit(exprEOF)
 		// next = nil
 	default:
-		return l.errorf("Unrecognized sequence in expression: %#U", cur)
+		name := takeIdentifier(l)
+		switch {
+		case name == "":
+			return l.errorf("Unrecognized sequence in expression: %#U", cur)
+		case l.peek() == '(':
+			l.take()
+			state.push(exprFunc)
+			l.emit(exprFunc)
+			next = lexExprText
+		case name == "true" || name == "false":
+			l.emit(exprBool)
+			next = lexOneValue
+		case name == "null":
+			l.emit(exprNull)
+			next = lexOneValue
+		default:
+			return l.errorf("Unrecognized sequence in expression: %q", name)
+		}
 	}
 	return next
 }
@@ -201,10 +262,14 @@ func lexOneValue(l lexer, state *intStack) stateFn {
 	case '=':
 		l.take()
 		cur = l.take()
-		if cur != '=' {
+		switch cur {
+		case '=':
+			l.emit(exprOpEq)
+		case '~':
+			l.emit(exprOpMatch)
+		default:
 			return l.errorf("Expected double = instead of %#U", cur)
 		}
-		l.emit(exprOpEq)
 		next = lexExprText
 	case '!':
 		l.take()
@@ -215,7 +280,7 @@ func lexOneValue(l lexer, state *intStack) stateFn {
 		l.emit(exprOpNeq)
 		next = lexExprText
 	case ')':
-		if top, ok := state.peek(); ok && top != exprParenLeft {
+		if top, ok := state.peek(); ok && top != exprParenLeft && top != exprFunc {
 			next = l.errorf("Received %#U but has no matching (", cur)
 			break
 		}
@@ -224,14 +289,98 @@ func lexOneValue(l lexer, state *intStack) stateFn {
 		l.emit(exprParenRight)
 
 		next = lexOneValue
+	case ',':
+		if top, ok := state.peek(); !ok || top != exprFunc {
+			return l.errorf("Received %#U outside of function arguments", cur)
+		}
+		l.take()
+		l.emit(exprComma)
+		next = lexExprText
 	case eof:
 		l.emit(exprEOF)
 	default:
-		return l.errorf("Unrecognized sequence in expression: %#U", cur)
+		word := takeWord(l)
+		op, ok := exprWordOperators[word]
+		if !ok {
+			if word == "" {
+				return l.errorf("Unrecognized sequence in expression: %#U", cur)
+			}
+			return l.errorf("Unrecognized operator in expression: %q", word)
+		}
+		l.emit(op)
+		next = lexExprText
 	}
 	return next
 }
 
+// takeIdentifier takes a name of letters, digits and underscores
+func takeIdentifier(l lexer) string {
+	var name []byte
+	for {
+		cur := l.peek()
+		if (cur < 'a' || cur > 'z') && (cur < 'A' || cur > 'Z') && cur != '_' &&
+			(len(name) == 0 || cur < '0' || cur > '9') {
+			return string(name)
+		}
+		name = append(name, byte(l.take()))
+	}
+}
+
+func takeWord(l lexer) string {
+	var word []byte
+	for {
+		cur := l.peek()
+		if (cur < 'a' || cur > 'z') && (cur < 'A' || cur > 'Z') {
+			return string(word)
+		}
+		word = append(word, byte(l.take()))
+	}
+}
+
+// takeRegex takes a regular expression literal such as /ab+c/i
+func takeRegex(l lexer) error {
+	if l.take() != '/' {
+		return errors.New("Expected / at start of regular expression")
+	}
+	for {
+		switch l.take() {
+		case '\\':
+			if l.take() == eof {
+				return errors.New("Unexpected EOF in regular expression")
+			}
+		case '/':
+			takeWord(l) // flags
+			return nil
+		case eof:
+			return errors.New("Unexpected EOF in regular expression")
+		}
+	}
+}
+
+// takeList takes a list literal such as ['a', "b", 1]
+func takeList(l lexer) error {
+	if l.take() != '[' {
+		return errors.New("Expected [ at start of list")
+	}
+	for {
+		switch l.peek() {
+		case '"', '\'':
+			if err := takeQuoted(l); err != nil {
+				return err
+			}
+			continue
+		case ']':
+			l.take()
+			return nil
+		case '[':
+			return errors.New("Lists cannot be nested")
+		case eof:
+			return errors.New("Unexpected EOF in list")
+		}
+		l.take()
+	}
+}
+
 func takeNumeric(l lexer) {
 	takeDigits(l)
 	if l.peek() == '.' {
@@ -249,31 +398,30 @@ func takeNumeric(l lexer) {
 	}
 }
 
+// takePath takes a path up to whitespace or an operator outside of its
+// brackets and quoted keys
 func takePath(l lexer) {
-	inQuotes := false
-	var prev int = 0
-	// capture until end of path - ugly
-takeLoop:
+	depth := 0
 	for {
 		cur := l.peek()
 		switch cur {
-		case '"':
-			if prev != '\\' {
-				inQuotes = !inQuotes
+		case '"', '\'':
+			if takeQuoted(l) != nil {
+				return
 			}
-			l.take()
-		case ' ':
-			if !inQuotes {
-				break takeLoop
+			continue
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ' ', '\t', '\r', '\n', '=', '<', '>', '!', '&', '|', ')', ',':
+			if depth == 0 {
+				return
 			}
-			l.take()
 		case eof:
-			break takeLoop
-		default:
-			l.take()
+			return
 		}
-
-		prev = cur
+		l.take()
 	}
 }
 
@@ -13,6 +13,9 @@ var expressionTests = []lexTest{
 	{"numbers", " 1.3e10 ", []int{exprNumber, exprEOF}},
 	// {"numbers with signs", "+1 -2.23", []int{exprNumber, exprOpPlus, exprNumber, exprEOF}},
 	{"paths", " @.aKey[2].bKey ", []int{exprPath, exprEOF}},
+	{"root paths", "@.owner == $.users[0]", []int{exprPath, exprOpEq, exprPath, exprEOF}},
+	{"paths before operators", "@.a==@['b c']", []int{exprPath, exprOpEq, exprPath, exprEOF}},
+	{"single quoted strings", "'it\\'s' == \"x\"", []int{exprString, exprOpEq, exprString, exprEOF}},
 	{"addition with mixed sign", "4+-19", []int{exprNumber, exprOpPlus, exprOpMinusUn, exprNumber, exprEOF}},
 	{"addition", "4+19", []int{exprNumber, exprOpPlus, exprNumber, exprEOF}},
 	{"subtraction", "4-19", []int{exprNumber, exprOpMinus, exprNumber, exprEOF}},
@@ -20,6 +23,11 @@ var expressionTests = []lexTest{
 	{"parens", "( () + () )", []int{exprParenLeft, exprParenLeft, exprParenRight, exprOpPlus, exprParenLeft, exprParenRight, exprParenRight, exprEOF}},
 	{"equals", "true ==", []int{exprBool, exprOpEq, exprEOF}},
 	{"numerical comparisons", "3.4 <", []int{exprNumber, exprOpLt, exprEOF}},
+	{"functions", "length(@.a) == count(@.b[*]) && f()", []int{exprFunc, exprPath, exprParenRight, exprOpEq, exprFunc, exprPath, exprParenRight, exprOpAnd, exprFunc, exprParenRight, exprEOF}},
+	{"function arguments", "match(@.a, 'x') || null", []int{exprFunc, exprPath, exprComma, exprString, exprParenRight, exprOpOr, exprNull, exprEOF}},
+	{"regex match", "@.a =~ /a(b)\\/[)]/i", []int{exprPath, exprOpMatch, exprRegex, exprEOF}},
+	{"lists", "@.a in ['x]', \"y\", 3]", []int{exprPath, exprOpIn, exprList, exprEOF}},
+	{"word operators", "@.a contains 'b' && @.a startsWith 'c' || @.a endsWith 'd'", []int{exprPath, exprOpContains, exprString, exprOpAnd, exprPath, exprOpStartsWith, exprString, exprOpOr, exprPath, exprOpEndsWith, exprString, exprEOF}},
 }
 
 func TestExpressionTokens(t *testing.T) {
@@ -1,6 +1,8 @@
 package jsonpath
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -22,6 +24,18 @@ var exprTests = []struct {
 	{"true || false", nil, true},
 	{"false ||  false", nil, false},
 
+	// && binds tighter than || and both skip their right operand once the
+	// left one decides
+	{"true || false && false", nil, true},
+	{"false && false || true", nil, true},
+	{"false && @.missing", nil, false},
+	{"true || @.missing", nil, true},
+	{"false && length(@.missing) > 1", nil, false},
+	{"true && !(1 > 2) || @.missing == 3", nil, true},
+	{"@.x != null && @.x.y > 1", map[string]Item{"@.x": genValue(`null`, jsonNull)}, false},
+	{"@.x != null && @.x.y > 1", map[string]Item{"@.x": genValue(`{"y":2}`, jsonBraceLeft), "@.x.y": genValue(`2`, jsonNumber)}, true},
+	{"@.x == null || @.x.y > 1", map[string]Item{"@.x": genValue(`null`, jsonNull)}, true},
+
 	// LT
 	{"10 < 20", nil, true},
 	{"10 < 10", nil, false},
@@ -83,6 +97,21 @@ var exprTests = []struct {
 	{`@a != 3.4`, map[string]Item{"@a": genValue(`3.4`, jsonNumber)}, false},
 	{`@a != 3.4`, map[string]Item{"@a": genValue(`3.41`, jsonNumber)}, true},
 	{`@a != null`, map[string]Item{"@a": genValue(`null`, jsonNull)}, false},
+	// values of different types are never equal
+	{"@a == @b", map[string]Item{"@a": genValue(`"one"`, jsonString), "@b": genValue("3.4", jsonNumber)}, false},
+	{"20 == null", nil, false},
+	{`"toronto" == null`, nil, false},
+	{`false == 20`, nil, false},
+	{`"nick" == 20`, nil, false},
+	{"20 != null", nil, true},
+	{`"toronto" != null`, nil, true},
+	{`false != 20`, nil, true},
+	{`"nick" != 20`, nil, true},
+	{`@a == null`, map[string]Item{"@a": genValue(`3.41`, jsonNumber)}, false},
+	{`@a != null`, map[string]Item{"@a": genValue(`3`, jsonNumber)}, true},
+	{`@a != null`, map[string]Item{"@a": genValue(`{"y":2}`, jsonBraceLeft)}, true},
+	{`@a == "x"`, map[string]Item{"@a": genValue(`["x"]`, jsonBracketLeft)}, false},
+	{`@a == [1]`, map[string]Item{"@a": genValue(`1`, jsonNumber)}, false},
 
 	// Plus
 	{"20 + 7", nil, 27},
@@ -123,10 +152,48 @@ var exprTests = []struct {
 	{"!true", nil, false},
 	{"!false", nil, true},
 
+	// String ordering
+	{`"apple" < "banana"`, nil, true},
+	{`"a" < "a!"`, nil, true},
+	{`"b" <= "a"`, nil, false},
+	{`@a >= "m"`, map[string]Item{"@a": genValue(`"zebra"`, jsonString)}, true},
+	{`@a > "caf"`, map[string]Item{"@a": genValue(`"caf\u00e9"`, jsonString)}, true},
+
+	// Match
+	{`@a =~ /^to.*o$/`, map[string]Item{"@a": genValue(`"toronto"`, jsonString)}, true},
+	{`@a =~ /^TO/i`, map[string]Item{"@a": genValue(`"toronto"`, jsonString)}, true},
+	{`@a =~ /^TO/`, map[string]Item{"@a": genValue(`"toronto"`, jsonString)}, false},
+	{`"a/b" =~ /a\/b/`, nil, true},
+
+	// In
+	{`@a in ['x', "toronto"]`, map[string]Item{"@a": genValue(`"toronto"`, jsonString)}, true},
+	{`@a in [1, 2.5, true]`, map[string]Item{"@a": genValue(`2.5`, jsonNumber)}, true},
+	{`@a in [1, "2.5"]`, map[string]Item{"@a": genValue(`2.5`, jsonNumber)}, false},
+	{`@a in []`, map[string]Item{"@a": genValue(`null`, jsonNull)}, false},
+
+	// Substrings
+	{`"toronto" contains "ron"`, nil, true},
+	{`"toronto" contains "x"`, nil, false},
+	{`@a startsWith 'tor'`, map[string]Item{"@a": genValue(`"toronto"`, jsonString)}, true},
+	{`@a endsWith "tor"`, map[string]Item{"@a": genValue(`"toronto"`, jsonString)}, false},
+	{`@a endsWith "nto" && @a startsWith "t"`, map[string]Item{"@a": genValue(`"toronto"`, jsonString)}, true},
+
+	// Functions
+	{`length("abc") == 3`, nil, true},
+	{`length(3)`, nil, Nothing},
+	{`match("abc", "a.c" ) && !match("abc", "a") && !search("abc", "^b")`, nil, true},
+
 	// Mix
 	{"20 >= 20 || 2 == 2", nil, true},
 	{"20 > @.test && @.test < 13 && @.test > 1.99994", map[string]Item{"@.test": genValue(`10.23423`, jsonNumber)}, true},
 	{"20 > @.test && @.test < 13 && @.test > 1.99994", map[string]Item{"@.test": genValue(`15.3423`, jsonNumber)}, false},
+
+	// objects and arrays compare by value
+	{"@.a == @.b", map[string]Item{"@.a": genValue(`{"x":[1,2],"y":null}`, jsonBraceLeft), "@.b": genValue(`{"y":null, "x":[1, 2]}`, jsonBraceLeft)}, true},
+	{"@.a != @.b", map[string]Item{"@.a": genValue(`[1,2]`, jsonBracketLeft), "@.b": genValue(`[2,1]`, jsonBracketLeft)}, true},
+	{"@.tags == ['a', \"b\"]", map[string]Item{"@.tags": genValue(`["a","b"]`, jsonBracketLeft)}, true},
+	{"@.tags != ['a']", map[string]Item{"@.tags": genValue(`["a","b"]`, jsonBracketLeft)}, true},
+	{"@.tags in ['a', 'b']", map[string]Item{"@.tags": genValue(`["a","b"]`, jsonBracketLeft)}, false},
 }
 
 func genValue(val string, typ int) Item {
@@ -159,22 +226,64 @@ func TestExpressions(t *testing.T) {
 	}
 }
 
+// precedenceLevels lists the binary operators from the loosest to the
+// tightest binding, all of them left associative
+var precedenceLevels = [][]int{
+	{exprOpOr},
+	{exprOpAnd},
+	{exprOpEq, exprOpNeq},
+	{exprOpLt, exprOpLe, exprOpGt, exprOpGe, exprOpMatch, exprOpIn, exprOpContains, exprOpStartsWith, exprOpEndsWith},
+	{exprOpPlus, exprOpMinus},
+	{exprOpStar, exprOpSlash, exprOpPercent},
+	{exprOpHat},
+}
+
+func TestOperatorPrecedence(t *testing.T) {
+	as := assert.New(t)
+
+	level := map[int]int{}
+	for l, ops := range precedenceLevels {
+		for _, op := range ops {
+			level[op] = l
+		}
+	}
+	for op, p := range opa {
+		if !p.rAssoc {
+			_, ok := level[op]
+			as.True(ok, "%s is missing from the matrix", exprTokenNames[op])
+		}
+	}
+
+	for o1 := range level {
+		for o2 := range level {
+			input := fmt.Sprintf("@.a %s @.b %s @.c", exprTokenNames[o1], exprTokenNames[o2])
+			lexer := NewSliceLexer([]byte(input), EXPRESSION)
+			items := readerToArray(lexer)
+			items_post, err := infixToPostFix(items[:len(items)-1])
+			if !as.NoError(err, input) {
+				continue
+			}
+			// (a o1 b) o2 c unless o2 binds tighter: a o1 (b o2 c)
+			expected := []int{exprPath, exprPath, o1, exprPath, o2}
+			if level[o2] > level[o1] {
+				expected = []int{exprPath, exprPath, exprPath, o2, o1}
+			}
+			actual := make([]int, len(items_post))
+			for x, item := range items_post {
+				actual[x] = item.typ
+			}
+			as.Equal(expected, actual, input)
+		}
+	}
+}
+
 var exprErrorTests = []struct {
 	input                  string
 	fields                 map[string]Item
 	expectedErrorSubstring string
 }{
-	{"@a == @b", map[string]Item{"@a": genValue(`"one"`, jsonString), "@b": genValue("3.4", jsonNumber)}, "cannot be compared"},
 	{")(", nil, "Mismatched parentheses"},
 	{")123", nil, "Mismatched parentheses"},
-	{"20 == null", nil, "cannot be compared"},
-	{`"toronto" == null`, nil, "cannot be compared"},
-	{`false == 20`, nil, "cannot be compared"},
-	{`"nick" == 20`, nil, "cannot be compared"},
-	{"20 != null", nil, "cannot be compared"},
-	{`"toronto" != null`, nil, "cannot be compared"},
-	{`false != 20`, nil, "cannot be compared"},
-	{`"nick" != 20`, nil, "cannot be compared"},
 	{``, nil, "Bad Expression"},
 	{`==`, nil, "Bad Expression"},
 	{`!=`, nil, "Not enough operands"},
@@ -194,7 +303,13 @@ var exprErrorTests = []struct {
 	{`"nick"-`, nil, "cannot be compared"},
 	{`"nick"^3.2`, nil, "cannot be compared"},
 
-	{`@a == null`, map[string]Item{"@a": genValue(`3.41`, jsonNumber)}, "cannot be compared"},
+	{`3.2 < "nick"`, nil, "cannot be compared"},
+	{`@a =~ /3/`, map[string]Item{"@a": genValue(`3.41`, jsonNumber)}, "cannot be compared"},
+	{`"nick" =~ "n"`, nil, "Operand type expected to be \"regex\""},
+	{`"nick" =~ /n/x`, nil, "Unsupported regular expression flag"},
+	{`"nick" =~ /(/`, nil, "Invalid regular expression"},
+	{`"nick" in "nick"`, nil, "Operand type expected to be \"list\""},
+	{`"nick" contains 3`, nil, "cannot be compared"},
 }
 
 func TestBadExpressions(t *testing.T) {
@@ -224,3 +339,189 @@ func TestBadExpressions(t *testing.T) {
 		}
 	}
 }
+
+// evaluatePostFix interprets a postfix filter item by item. It is the
+// reference the compiled filters are measured against.
+func evaluatePostFix(postFixItems []Item, pathValues map[string]Item) (interface{}, error) {
+	return evaluatePostFixDialect(postFixItems, pathValues, nil, dialectDefault, nil)
+}
+
+// evaluatePostFixDialect evaluates a filter. pathValues holds the first value
+// of each path and pathNodes all of them, if known. Regular expressions,
+// lists and functions are taken from op when it has them cached.
+func evaluatePostFixDialect(postFixItems []Item, pathValues map[string]Item, pathNodes map[string][]Item, dialect int, op *operator) (interface{}, error) {
+	s := newStack()
+
+	if len(postFixItems) == 0 {
+		return false, errors.New(exprErrorBadExpression)
+	}
+
+	skips := shortCircuits(postFixItems)
+	for x := 0; x < len(postFixItems); x++ {
+		item := postFixItems[x]
+		if end, ok := skips[x]; ok {
+			// the left operand of && or || is on the stack and may decide it
+			if left, ok := s.peek(); ok && shortCircuit(postFixItems[end].typ, left) {
+				x = end
+				continue
+			}
+		}
+		switch item.typ {
+		case exprRegex, exprList, exprBool, exprNumber, exprString, exprNull:
+			val, err := literalValue(item, dialect, op)
+			if err != nil {
+				return false, err
+			}
+			s.push(val)
+		case exprPath:
+			val, err := operandValue(item, pathValues, dialect, op.exactNumbers())
+			if err != nil {
+				return false, err
+			}
+			s.push(val)
+		case exprPathExists:
+			s.push(pathExists(item, pathValues, dialect))
+		case exprPathValue:
+			val, err := functionValue(item, pathValues)
+			if err != nil {
+				return false, err
+			}
+			s.push(val)
+		case exprPathNodes:
+			val, err := functionNodes(item, pathValues, pathNodes)
+			if err != nil {
+				return false, err
+			}
+			s.push(val)
+		case exprFuncStart:
+			s.push(funcArgsStart{})
+		case exprFunc:
+			f, err := op.function(item.val)
+			if err != nil {
+				return false, err
+			}
+			var args []interface{}
+			for {
+				a, ok := s.pop()
+				if !ok {
+					return false, errors.New(exprErrorMismatchedParens)
+				}
+				if _, ok := a.(funcArgsStart); ok {
+					break
+				}
+				args = append([]interface{}{a}, args...)
+			}
+			res, err := callFunction(f, args, dialect)
+			if err != nil {
+				return false, err
+			}
+			s.push(res)
+		case exprOpNot, exprOpExclam, exprOpPlusUn, exprOpMinusUn:
+			a, ok := s.pop()
+			if !ok {
+				return false, fmt.Errorf(exprErrorNotEnoughOperands, exprTokenNames[item.typ])
+			}
+			res, err := applyUnary(item.typ, a)
+			if err != nil {
+				return false, err
+			}
+			s.push(res)
+		default:
+			if _, isOp := opa[item.typ]; !isOp {
+				return false, fmt.Errorf("Token not supported in evaluator: %v", exprTokenNames[item.typ])
+			}
+			b, okB := s.pop()
+			a, okA := s.pop()
+			if !okA || !okB {
+				return false, missingOperandError(item.typ, b, okB, dialect)
+			}
+			res, err := applyBinary(item.typ, a, b, dialect)
+			if err != nil {
+				return false, err
+			}
+			s.push(res)
+		}
+	}
+
+	if s.len() != 1 {
+		return false, fmt.Errorf(exprErrorBadExpression)
+	}
+	end_int, _ := s.pop()
+	return end_int, nil
+}
+
+// shortCircuits maps the first item of the right operand of each && and ||
+// to the index of the operator
+func shortCircuits(postFix []Item) map[int]int {
+	start := make([]int, len(postFix))
+	for x := range postFix {
+		start[x] = x
+	}
+	skips := make(map[int]int)
+	_, err := walkPostFix(postFix, func(x int, operands []int) error {
+		switch {
+		case postFix[x].typ == exprFunc:
+			// the call starts at its marker, right before its arguments
+			start[x] = x - 1
+			if len(operands) > 0 {
+				start[x] = start[operands[0]] - 1
+			}
+		case len(operands) > 0:
+			start[x] = start[operands[0]]
+		}
+		if t := postFix[x].typ; (t == exprOpAnd || t == exprOpOr) && len(operands) == 2 {
+			skips[start[operands[1]]] = x
+		}
+		return nil
+	})
+	if err != nil {
+		return nil // malformed, reported when it is evaluated
+	}
+	return skips
+}
+
+// funcArgsStart marks where the arguments of a function call begin on the
+// evaluation stack
+type funcArgsStart struct{}
+
+var benchFilter = `(@.price * 1.1 > 10 && @.category == "fiction") || @.title startsWith "A"`
+
+var benchFilterValues = map[string]Item{
+	"@.price":    genValue(`12.5`, jsonNumber),
+	"@.category": genValue(`"fiction"`, jsonString),
+	"@.title":    genValue(`"Moby Dick"`, jsonString),
+}
+
+func benchFilterPostFix(b *testing.B) []Item {
+	lexer := NewSliceLexer([]byte(benchFilter), EXPRESSION)
+	items := readerToArray(lexer)
+	postFix, err := infixToPostFix(items[0 : len(items)-1])
+	if err != nil {
+		b.Fatal(err)
+	}
+	return postFix
+}
+
+func BenchmarkFilterInterpreted(b *testing.B) {
+	postFix := benchFilterPostFix(b)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := evaluatePostFix(postFix, benchFilterValues); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFilterCompiled(b *testing.B) {
+	filter, err := compileFilter(benchFilterPostFix(b), dialectDefault, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	env := &filterEnv{values: benchFilterValues}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := filter(env); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ArgType is the type of a filter function parameter or result, as in
+// RFC 9535
+type ArgType int
+
+const (
+	// ValueType is a JSON value, or Nothing when there is none
+	ValueType ArgType = iota
+	// LogicalType is true or false
+	LogicalType
+	// NodesType is every value a path selects, passed as []interface{}
+	NodesType
+)
+
+// Signature describes the parameters and the result of a filter function
+type Signature struct {
+	Params []ArgType
+	Result ArgType
+}
+
+// Function implements a filter function. It receives one argument per
+// parameter, decoded like encoding/json decodes into an interface{}, and
+// returns a value or Nothing, or a bool for a LogicalType result.
+type Function func(args []interface{}) interface{}
+
+// Nothing is the value of a missing value in filter functions
+var Nothing interface{} = nothing{}
+
+type filterFunction struct {
+	name      string
+	signature Signature
+	fn        Function
+}
+
+var (
+	functionsLock sync.RWMutex
+	functions     = map[string]*filterFunction{}
+	functionName  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+func init() {
+	builtins := []struct {
+		name      string
+		signature Signature
+		fn        Function
+	}{
+		{"length", Signature{[]ArgType{ValueType}, ValueType}, lengthFunction},
+		{"count", Signature{[]ArgType{NodesType}, ValueType}, countFunction},
+		{"match", Signature{[]ArgType{ValueType, ValueType}, LogicalType}, matchFunction},
+		{"search", Signature{[]ArgType{ValueType, ValueType}, LogicalType}, searchFunction},
+		{"value", Signature{[]ArgType{NodesType}, ValueType}, valueFunction},
+	}
+	for _, b := range builtins {
+		if err := RegisterFunction(b.name, b.signature, b.fn); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterFunction makes a function available to the filters of paths parsed
+// afterwards, such as ipInCIDR(@.addr, "10.0.0.0/8")
+func RegisterFunction(name string, signature Signature, fn Function) error {
+	switch {
+	case !functionName.MatchString(name):
+		return fmt.Errorf("Invalid function name %q", name)
+	case name == "true" || name == "false" || name == "null" || exprWordOperators[name] != 0:
+		return fmt.Errorf("Function name %q is reserved", name)
+	case fn == nil:
+		return errors.New("Function cannot be nil")
+	case signature.Result == NodesType:
+		return errors.New("Functions cannot return nodes")
+	}
+	for _, p := range signature.Params {
+		if p != ValueType && p != LogicalType && p != NodesType {
+			return fmt.Errorf("Invalid parameter type %d", p)
+		}
+	}
+
+	functionsLock.Lock()
+	defer functionsLock.Unlock()
+	if _, ok := functions[name]; ok {
+		return fmt.Errorf("Function %s is already registered", name)
+	}
+	params := make([]ArgType, len(signature.Params))
+	copy(params, signature.Params)
+	functions[name] = &filterFunction{name, Signature{params, signature.Result}, fn}
+	return nil
+}
+
+func lookupFunction(name string) (*filterFunction, error) {
+	functionsLock.RLock()
+	defer functionsLock.RUnlock()
+	f, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown function %s()", name)
+	}
+	return f, nil
+}
+
+// call converts the arguments from their form on the evaluation stack, calls
+// the function and converts the result back
+func (f *filterFunction) call(args []interface{}, dialect int) (interface{}, error) {
+	for x, a := range args {
+		v, err := functionArg(a)
+		if err != nil {
+			return nil, err
+		}
+		args[x] = v
+	}
+
+	res := f.fn(args)
+	if f.signature.Result == LogicalType {
+		b, _ := res.(bool)
+		return b, nil
+	}
+	switch v := res.(type) {
+	case nothing, nil, bool, float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case string:
+		if dialect == dialectDefault {
+			return quoteString(v), nil
+		}
+		return v, nil
+	case []interface{}, map[string]interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return jsonComposite(b), nil
+	}
+	return nil, fmt.Errorf("Function %s() returned unsupported type %T", f.name, res)
+}
+
+// nodeList holds the values of a NodesType argument
+type nodeList []interface{}
+
+func functionArg(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case []byte:
+		s, ok := filterString(v)
+		if !ok {
+			return nil, fmt.Errorf(exprErrorBadValue, string(v), jsonTokenNames[jsonString])
+		}
+		return s, nil
+	case jsonComposite:
+		var d interface{}
+		if err := json.Unmarshal(v, &d); err != nil {
+			return nil, err
+		}
+		return d, nil
+	case exactComposite:
+		return functionArg(jsonComposite(v))
+	case *big.Rat:
+		f, _ := v.Float64()
+		return f, nil
+	case nodeList:
+		return []interface{}(v), nil
+	}
+	return val, nil
+}
+
+// decodeFunctionValue decodes a path value for a function argument
+func decodeFunctionValue(i Item) (interface{}, error) {
+	if i.typ == jsonBraceLeft || i.typ == jsonBracketLeft {
+		var v interface{}
+		if err := json.Unmarshal(i.val, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	return decodeRFC9535Value(i)
+}
+
+func lengthFunction(args []interface{}) interface{} {
+	switch v := args[0].(type) {
+	case string:
+		return float64(utf8.RuneCountInString(v))
+	case []interface{}:
+		return float64(len(v))
+	case map[string]interface{}:
+		return float64(len(v))
+	}
+	return Nothing
+}
+
+func countFunction(args []interface{}) interface{} {
+	return float64(len(args[0].([]interface{})))
+}
+
+func valueFunction(args []interface{}) interface{} {
+	if nodes := args[0].([]interface{}); len(nodes) == 1 {
+		return nodes[0]
+	}
+	return Nothing
+}
+
+func matchFunction(args []interface{}) interface{} {
+	return regexFunction(args, true)
+}
+
+func searchFunction(args []interface{}) interface{} {
+	return regexFunction(args, false)
+}
+
+var (
+	iRegexpsLock sync.Mutex
+	iRegexps     = map[string]*regexp.Regexp{}
+)
+
+func regexFunction(args []interface{}, full bool) bool {
+	s, okS := args[0].(string)
+	pattern, okP := args[1].(string)
+	if !okS || !okP {
+		return false
+	}
+	re, err := compileIRegexp(pattern, full)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(s)
+}
+
+// compileIRegexp compiles an RFC 9485 I-Regexp, anchored at both ends when
+// full is set. Patterns are cached since they are usually literals.
+func compileIRegexp(pattern string, full bool) (*regexp.Regexp, error) {
+	key := pattern
+	if full {
+		key = "^(?:" + pattern + ")$"
+	}
+	iRegexpsLock.Lock()
+	defer iRegexpsLock.Unlock()
+	if re, ok := iRegexps[key]; ok {
+		return re, nil
+	}
+
+	// . does not match line breaks in I-Regexp
+	var sb strings.Builder
+	inClass := false
+	for x := 0; x < len(pattern); x++ {
+		c := pattern[x]
+		switch {
+		case c == '\\' && x+1 < len(pattern):
+			sb.WriteByte(c)
+			x++
+			c = pattern[x]
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '.' && !inClass:
+			sb.WriteString(`[^\n\r]`)
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	source := sb.String()
+	if full {
+		source = "^(?:" + source + ")$"
+	}
+	re, err := regexp.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(iRegexps) < 1000 {
+		iRegexps[key] = re
+	}
+	return re, nil
+}
@@ -0,0 +1,110 @@
+package jsonpath
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errRegisterIPInCIDR = RegisterFunction("ipInCIDR", Signature{[]ArgType{ValueType, ValueType}, LogicalType}, func(args []interface{}) interface{} {
+	addr, _ := args[0].(string)
+	cidr, _ := args[1].(string)
+	_, network, err := net.ParseCIDR(cidr)
+	return err == nil && network.Contains(net.ParseIP(addr))
+})
+
+const functionsJSON = `{"items":[
+	{"name":"alpha", "tags":["a","b"], "addr":"10.1.2.3", "meta":{"x":1}},
+	{"name":"beta", "tags":[], "addr":"192.168.0.1"},
+	{"name":"gämma", "tags":["c"], "addr":"10.0.0.9", "meta":{"x":2,"y":3}}
+]}`
+
+func TestFunctions(t *testing.T) {
+	as := assert.New(t)
+	as.NoError(errRegisterIPInCIDR)
+
+	tests := []struct {
+		path     string
+		rfc9535  bool
+		expected []string
+	}{
+		{`$.items[*]?(length(@.name) == 5).name+`, false, []string{`"alpha"`, `"gämma"`}},
+		{`$.items[*]?(length(@.tags) > 0 && length(@.meta) == 2).name+`, false, []string{`"gämma"`}},
+		{`$.items[?length(@.nope) == 1].name`, true, []string{}},
+		{`$.items[?count(@.tags[*]) == 2].name`, true, []string{`"alpha"`}},
+		{`$.items[?count(@.meta.*) > 0].name`, true, []string{`"alpha"`, `"gämma"`}},
+		{`$.items[?match(@.name, 'a.*a')].name`, true, []string{`"alpha"`}},
+		{`$.items[?search(@.name, 'ta')].name`, true, []string{`"beta"`}},
+		{`$.items[?!search(@.name, '^[ab]')].name`, true, []string{`"gämma"`}},
+		{`$.items[?value(@.meta.x) == 2].name`, true, []string{`"gämma"`}},
+		{`$.items[?value(@.tags[*]) == 'c'].name`, true, []string{`"gämma"`}},
+		{`$.items[*]?(ipInCIDR(@.addr, "10.0.0.0/8")).name+`, false, []string{`"alpha"`, `"gämma"`}},
+		{`$.items[?ipInCIDR(@.addr, '192.168.0.0/16')].name`, true, []string{`"beta"`}},
+	}
+
+	for _, test := range tests {
+		var paths []*Path
+		var err error
+		if test.rfc9535 {
+			paths, err = ParsePathsRFC9535(test.path)
+		} else {
+			paths, err = ParsePaths(test.path)
+		}
+		if !as.NoError(err, test.path) {
+			continue
+		}
+		eval, err := EvalPathsInBytes([]byte(functionsJSON), paths)
+		as.NoError(err)
+
+		actual := make([]string, 0)
+		for {
+			r, ok := eval.Next()
+			if !ok {
+				break
+			}
+			actual = append(actual, string(r.Value))
+		}
+		as.NoError(eval.Error, test.path)
+		as.EqualValues(test.expected, actual, test.path)
+	}
+}
+
+func TestFunctionErrors(t *testing.T) {
+	as := assert.New(t)
+
+	for path, msg := range map[string]string{
+		`$.a[*]?(nope(@.b))`:          `Unknown function nope()`,
+		`$.a[*]?(length(@.b, 1) > 1)`: `Function length() takes 1 arguments, not 2`,
+		`$.a[*]?(count(1) > 1)`:       `Argument 1 of count() must be a path`,
+		`$.a[*]?(@.b, 1)`:             `Received U+002C ',' outside of function arguments`,
+	} {
+		_, err := ParsePaths(path)
+		if as.Error(err, path) {
+			as.EqualError(err, msg)
+		}
+	}
+
+	noop := func(args []interface{}) interface{} { return nil }
+	as.EqualError(RegisterFunction("length", Signature{[]ArgType{ValueType}, ValueType}, noop), `Function length is already registered`)
+	as.EqualError(RegisterFunction("in", Signature{nil, LogicalType}, noop), `Function name "in" is reserved`)
+	as.EqualError(RegisterFunction("a-b", Signature{nil, LogicalType}, noop), `Invalid function name "a-b"`)
+	as.EqualError(RegisterFunction("nodes", Signature{nil, NodesType}, noop), `Functions cannot return nodes`)
+	as.EqualError(RegisterFunction("nilFunction", Signature{nil, ValueType}, nil), `Function cannot be nil`)
+}
+
+func TestIRegexp(t *testing.T) {
+	as := assert.New(t)
+
+	re, err := compileIRegexp(`a.c`, true)
+	if as.NoError(err) {
+		as.True(re.MatchString("abc"))
+		as.False(re.MatchString("a\nc"))
+		as.False(re.MatchString("xabc"))
+	}
+	re, err = compileIRegexp(`[.]\.`, false)
+	if as.NoError(err) {
+		as.True(re.MatchString("x.."))
+		as.False(re.MatchString("x.a"))
+	}
+}
@@ -1,5 +1,13 @@
 package jsonpath
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"unicode/utf8"
+)
+
 const (
 	jsonError = iota
 	jsonEOF
@@ -40,19 +48,60 @@ var jsonTokenNames = map[int]string{
 
 var JSON = lexJsonRoot
 
-func lexJsonRoot(l lexer, state *intStack) stateFn {
-	ignoreSpaceRun(l)
+// Markers at the bottom of the lexer stack of inputs holding several
+// documents. Each document ends with its own jsonEOF.
+const (
+	jsonLinesMarker = noValue - 1 - iota // separated by whitespace
+	jsonSeqMarker                        // each preceded by a record separator, as in RFC 7464
+)
+
+// recordSeparator starts each document of a JSON text sequence
+const recordSeparator = 0x1E
+
+func lexJsonLines(l lexer, state *intStack) stateFn {
+	state.push(jsonLinesMarker)
+	return stateJsonDocument
+}
+
+func lexJsonSeq(l lexer, state *intStack) stateFn {
+	state.push(jsonSeqMarker)
+	return stateJsonDocument
+}
+
+func stateJsonDocument(l lexer, state *intStack) stateFn {
+	seq := false
+	if top, ok := state.peek(); ok && top == jsonSeqMarker {
+		seq = true
+	}
+	separated := false
+	for {
+		ignoreSpaceRun(l)
+		if !seq || l.peek() != recordSeparator {
+			break
+		}
+		l.take()
+		separated = true
+	}
+
 	cur := l.peek()
-	var next stateFn
-	switch cur {
-	case '{':
-		next = stateJsonObjectOpen
-	case '[':
-		next = stateJsonArrayOpen
-	default:
-		next = l.errorf("Expected '{' or '[' at root of JSON instead of %#U", cur)
+	switch {
+	case cur == eof:
+		return nil
+	case seq && !separated:
+		return l.errorf("Expected record separator before document instead of %#U", cur)
 	}
-	return next
+	return stateJsonValue
+}
+
+func stateJsonAfterDocument(l lexer, state *intStack) stateFn {
+	l.emit(jsonEOF)
+	return stateJsonDocument
+}
+
+// lexJsonRoot accepts any value at the root, as RFC 8259 does
+func lexJsonRoot(l lexer, state *intStack) stateFn {
+	ignoreSpaceRun(l)
+	return stateJsonValue
 }
 
 func stateJsonObjectOpen(l lexer, state *intStack) stateFn {
@@ -93,6 +142,10 @@ func stateJsonObject(l lexer, state *intStack) stateFn {
 	case '"':
 		next = stateJsonKey
 	default:
+		if opts := l.options(); cur == '\'' && opts.SingleQuotes || isKeyStart(cur) && opts.UnquotedKeys {
+			next = stateJsonKey
+			break
+		}
 		next = l.errorf("Expected '}' or \" within an object instead of %#U", cur)
 	}
 	return next
@@ -118,8 +171,11 @@ func stateJsonArray(l lexer, state *intStack) stateFn {
 }
 
 func stateJsonAfterValue(l lexer, state *intStack) stateFn {
-	cur := l.take()
 	top, ok := state.peek()
+	if top == jsonLinesMarker || top == jsonSeqMarker {
+		return stateJsonAfterDocument
+	}
+	cur := l.take()
 	topVal := noValue
 	if ok {
 		topVal = top
@@ -127,6 +183,15 @@ func stateJsonAfterValue(l lexer, state *intStack) stateFn {
 
 	switch cur {
 	case ',':
+		if l.options().TrailingCommas {
+			// drop the comma with the space after it if the container closes
+			if err := skipSpace(l); err != nil {
+				return l.errorf("%s", err)
+			}
+			if next := l.peek(); next == '}' && topVal == jsonBraceLeft || next == ']' && topVal == jsonBracketLeft {
+				return stateJsonAfterValue
+			}
+		}
 		l.emit(jsonComma)
 		switch topVal {
 		case jsonBraceLeft:
@@ -174,8 +239,10 @@ func stateJsonAfterValue(l lexer, state *intStack) stateFn {
 }
 
 func stateJsonKey(l lexer, state *intStack) stateFn {
-	if err := l.takeString(); err != nil {
-		return l.errorf(err.Error())
+	if isKeyStart(l.peek()) && l.options().UnquotedKeys {
+		takeUnquotedKey(l)
+	} else if err := l.takeString(); err != nil {
+		return l.errorf("%s", err)
 	}
 	l.emit(jsonKey)
 
@@ -210,6 +277,11 @@ func stateJsonValue(l lexer, state *intStack) stateFn {
 		return stateJsonObjectOpen
 	case '[':
 		return stateJsonArrayOpen
+	case '\'':
+		if l.options().SingleQuotes {
+			return stateJsonString
+		}
+		return l.errorf("Unexpected character as start of value: %#U", cur)
 	default:
 		return l.errorf("Unexpected character as start of value: %#U", cur)
 	}
@@ -217,7 +289,7 @@ func stateJsonValue(l lexer, state *intStack) stateFn {
 
 func stateJsonString(l lexer, state *intStack) stateFn {
 	if err := l.takeString(); err != nil {
-		return l.errorf(err.Error())
+		return l.errorf("%s", err)
 	}
 	l.emit(jsonString)
 	return stateJsonAfterValue
@@ -225,7 +297,9 @@ func stateJsonString(l lexer, state *intStack) stateFn {
 
 func stateJsonNumber(l lexer, state *intStack) stateFn {
 	if err := takeJSONNumeric(l); err != nil {
-		return l.errorf(err.Error())
+		// point the error at the byte it is about
+		l.ignore()
+		return l.errorf("%s", err)
 	}
 	l.emit(jsonNumber)
 	return stateJsonAfterValue
@@ -264,3 +338,101 @@ func stateJsonAfterRoot(l lexer, state *intStack) stateFn {
 	l.emit(jsonEOF)
 	return nil
 }
+
+var commaBytes = []byte{','}
+
+// normalizeLenient rewrites a token of lenient input as standard JSON, or
+// returns nil if it already is
+func normalizeLenient(typ int, val []byte) []byte {
+	switch typ {
+	case jsonComma:
+		// a comma may have been taken with the space after it
+		if len(val) != 1 {
+			return commaBytes
+		}
+	case jsonKey, jsonString:
+		switch {
+		case len(val) == 0 || val[0] == '"':
+		case val[0] == '\'':
+			if s, err := unquoteString(val); err == nil {
+				return quoteString(s)
+			}
+		default:
+			return quoteString(string(val))
+		}
+	case jsonNumber:
+		if x := bytes.IndexAny(val, "xX"); x > 0 {
+			n, ok := new(big.Int).SetString(string(val[x+1:]), 16)
+			if !ok {
+				return nil
+			}
+			if val[0] == '-' {
+				n.Neg(n)
+			}
+			return []byte(n.String())
+		}
+	}
+	return nil
+}
+
+// checkSingleQuoted checks the escapes of a single-quoted string token, which
+// is rewritten as a JSON string, returning the offset of the first invalid
+// byte
+func checkSingleQuoted(s []byte) (int, error) {
+	end := len(s) - 1 // closing quote
+	for x := 1; x < end; x++ {
+		c := s[x]
+		switch {
+		case c < 0x20:
+			return x, fmt.Errorf("Invalid control character %#U in string", rune(c))
+		case c == '\\':
+			switch s[x+1] {
+			case '\'', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				x++
+			case 'u':
+				_, n, err := unescapeRune(s[x+2 : end])
+				if err != nil {
+					return x, err
+				}
+				x += n + 1
+			default:
+				return x, fmt.Errorf("Invalid escape character %#U in string", rune(s[x+1]))
+			}
+		}
+	}
+	return 0, nil
+}
+
+// checkJSONString checks a string token against RFC 8259, returning the
+// offset of the first invalid byte
+func checkJSONString(s []byte) (int, error) {
+	end := len(s) - 1 // closing quote
+	for x := 1; x < end; {
+		c := s[x]
+		switch {
+		case c < 0x20:
+			return x, fmt.Errorf("Invalid control character %#U in string", rune(c))
+		case c == '\\':
+			switch s[x+1] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				x += 2
+			case 'u':
+				if _, ok := hexRune(s[x+2 : end]); !ok {
+					return x, errors.New("Expected 4 hex digits after \\u in string")
+				}
+				x += 6
+			default:
+				return x, fmt.Errorf("Invalid escape character %#U in string", rune(s[x+1]))
+			}
+		case c < utf8.RuneSelf:
+			x++
+		default:
+			r, size := utf8.DecodeRune(s[x:end])
+			if r == utf8.RuneError && size == 1 {
+				return x, fmt.Errorf("Invalid UTF-8 byte %#x in string", c)
+			}
+			x += size
+		}
+	}
+	return 0, nil
+}
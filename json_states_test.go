@@ -1,6 +1,7 @@
 package jsonpath
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -20,6 +21,12 @@ var jsonTests = []lexTest{
 	{"key array", `{"key" :["45",{}]}`, []int{jsonBraceLeft, jsonKey, jsonColon, jsonBracketLeft, jsonString, jsonComma, jsonBraceLeft, jsonBraceRight, jsonBracketRight, jsonBraceRight, jsonEOF}},
 	{"key nestedObject", `{"key" :{"innerkey":"value"}}`, []int{jsonBraceLeft, jsonKey, jsonColon, jsonBraceLeft, jsonKey, jsonColon, jsonString, jsonBraceRight, jsonBraceRight, jsonEOF}},
 	{"key nestedArray", `[1,["a","b"]]`, []int{jsonBracketLeft, jsonNumber, jsonComma, jsonBracketLeft, jsonString, jsonComma, jsonString, jsonBracketRight, jsonBracketRight, jsonEOF}},
+	{"escaped backslash before quote", `{"a\\":"b\\"}`, []int{jsonBraceLeft, jsonKey, jsonColon, jsonString, jsonBraceRight, jsonEOF}},
+	{"escaped quotes", `["\"a\"", "\\\""]`, []int{jsonBracketLeft, jsonString, jsonComma, jsonString, jsonBracketRight, jsonEOF}},
+	{"string root", ` "hello" `, []int{jsonString, jsonEOF}},
+	{"number root", `-4.2e1`, []int{jsonNumber, jsonEOF}},
+	{"bool root", `true`, []int{jsonBool, jsonEOF}},
+	{"null root", "\nnull\n", []int{jsonNull, jsonEOF}},
 }
 
 func TestValidJson(t *testing.T) {
@@ -45,6 +52,9 @@ var errorJsonTests = []lexTest{
 	{"Missing values in array", `{"key":[,]`, []int{jsonBraceLeft, jsonKey, jsonColon, jsonBracketLeft, jsonError}},
 	{"Missing value after comma", `{"key":[343,]}`, []int{jsonBraceLeft, jsonKey, jsonColon, jsonBracketLeft, jsonNumber, jsonComma, jsonError}},
 	{"Missing comma in array", `{"key":[234 424]}`, []int{jsonBraceLeft, jsonKey, jsonColon, jsonBracketLeft, jsonNumber, jsonError}},
+	{"Empty document", ` `, []int{jsonError}},
+	{"Several root values", `1 2`, []int{jsonNumber, jsonError}},
+	{"Comma after root value", `"a",`, []int{jsonString, jsonError}},
 }
 
 func TestMalformedJson(t *testing.T) {
@@ -170,3 +180,120 @@ func TestMixedCaseJson(t *testing.T) {
 		}
 	}
 }
+
+func TestStrictJson(t *testing.T) {
+	as := assert.New(t)
+
+	valid := []string{
+		`{"a":"\"\\\/\b\f\n\r\té😀","b":[0,-0,0.5,-0.5e10,10,1E+2]}`,
+		`"café ☕"`,
+		`[ "", {} ]`,
+	}
+	for _, input := range valid {
+		for _, lexer := range []lexer{NewSliceLexer([]byte(input), JSON), NewReaderLexer(strings.NewReader(input), JSON)} {
+			lexer.(optionsLexer).setOptions(Options{Strict: true})
+			items := readerToArray(lexer)
+			as.Equal(jsonEOF, items[len(items)-1].typ, "%q: %s", input, items[len(items)-1].val)
+		}
+	}
+
+	invalid := []struct {
+		input  string
+		msg    string
+		pos    Pos
+		column int
+	}{
+		{"[\"a\tb\"]", "Invalid control character U+0009 in string", 3, 4},
+		{`{"a\x":1}`, "Invalid escape character U+0078 'x' in string", 3, 4},
+		{`["\u12G4"]`, `Expected 4 hex digits after \u in string`, 2, 3},
+		{`["\u12"]`, `Expected 4 hex digits after \u in string`, 2, 3},
+		{"[\"ab\xff\"]", "Invalid UTF-8 byte 0xff in string", 4, 5},
+		{"[\"\xc3\"]", "Invalid UTF-8 byte 0xc3 in string", 2, 3},
+		{`[01]`, "Unexpected digit U+0031 '1' after leading zero", 2, 3},
+		{`[-00.5]`, "Unexpected digit U+0030 '0' after leading zero", 3, 4},
+	}
+	for _, test := range invalid {
+		for _, lexer := range []lexer{NewSliceLexer([]byte(test.input), JSON), NewReaderLexer(strings.NewReader(test.input), JSON)} {
+			lexer.(optionsLexer).setOptions(Options{Strict: true})
+			items := readerToArray(lexer)
+			last := items[len(items)-1]
+			if as.Equal(jsonError, last.typ, test.input) {
+				as.Equal(test.msg, string(last.val), test.input)
+				as.Equal(test.pos, last.pos, test.input)
+				as.Equal(test.column, last.column, test.input)
+			}
+		}
+
+		// lenient by default
+		items := readerToArray(NewSliceLexer([]byte(test.input), JSON))
+		as.Equal(jsonEOF, items[len(items)-1].typ, test.input)
+	}
+}
+
+func TestLenientJson(t *testing.T) {
+	as := assert.New(t)
+
+	all := Options{Comments: true, TrailingCommas: true, SingleQuotes: true, UnquotedKeys: true, HexNumbers: true}
+	tests := []struct {
+		input  string
+		opts   Options
+		values []string
+	}{
+		{"// config\n{\"a\": /* one */ 1} // end", Options{Comments: true}, []string{`{`, `"a"`, `:`, `1`, `}`, ``}},
+		{"[1, /* a, b */\n 2]/**/", Options{Comments: true}, []string{`[`, `1`, `,`, `2`, `]`, ``}},
+		{`{"a":[1,2,],}`, Options{TrailingCommas: true}, []string{`{`, `"a"`, `:`, `[`, `1`, `,`, `2`, `]`, `}`, ``}},
+		{"[1 , // last\n]", Options{TrailingCommas: true, Comments: true}, []string{`[`, `1`, `]`, ``}},
+		{`{'a':'it\'s "x"'}`, Options{SingleQuotes: true}, []string{`{`, `"a"`, `:`, `"it's \"x\""`, `}`, ``}},
+		{`{a: 1, _b$2: 2}`, Options{UnquotedKeys: true}, []string{`{`, `"a"`, `:`, `1`, `,`, `"_b$2"`, `:`, `2`, `}`, ``}},
+		{`[0x1F, -0Xff, 0x10000000000000000]`, Options{HexNumbers: true}, []string{`[`, `31`, `,`, `-255`, `,`, `18446744073709551616`, `]`, ``}},
+		{"{a: 'x', /* c */ b: [0x0A,],}", all, []string{`{`, `"a"`, `:`, `"x"`, `,`, `"b"`, `:`, `[`, `10`, `]`, `}`, ``}},
+	}
+	for _, test := range tests {
+		for _, lexer := range []lexer{NewSliceLexer([]byte(test.input), JSON), NewReaderLexer(strings.NewReader(test.input), JSON)} {
+			lexer.(optionsLexer).setOptions(test.opts)
+			items := readerToArray(lexer)
+			values := make([]string, len(items))
+			for x, i := range items {
+				values[x] = string(i.val)
+			}
+			as.Equal(test.values, values, test.input)
+		}
+
+		// standard JSON by default
+		items := readerToArray(NewSliceLexer([]byte(test.input), JSON))
+		as.Equal(jsonError, items[len(items)-1].typ, test.input)
+	}
+
+	invalid := []struct {
+		input string
+		msg   string
+		pos   Pos
+	}{
+		{`[1, / 2]`, "Expected '/' or '*' after '/' instead of U+0020 ' '", 4},
+		{`[1 /* 2]`, "Unexpected EOF in comment", 3},
+		{`[1,,]`, "Unexpected character as start of value: U+002C ','", 3},
+		{`[,]`, "Unexpected character as start of value: U+002C ','", 1},
+		{`{"a":1,]`, "Expected \" as start of string instead of U+005D ']'", 7},
+		{`{1a: 1}`, "Expected '}' or \" within an object instead of U+0031 '1'", 1},
+		{`['a"]`, "End of file where string expected", 1},
+		{`[0x]`, "Expected hex digit after 0x instead of U+005D ']'", 3},
+		{`[0x`, "Expected hex digit after 0x instead of EOF", 3},
+		{`{/% x`, "Expected '/' or '*' after '/' instead of U+0025 '%'", 1},
+		{`[1 /`, "Expected '/' or '*' after '/' instead of EOF", 3},
+		{`[0x1.5]`, "Unexpected character after json value token: U+002E '.'", 4},
+		{`{"b": 'x\y'}`, "Invalid escape character U+0079 'y' in string", 8},
+		{`{'a\q': 1}`, "Invalid escape character U+0071 'q' in string", 3},
+		{`['\"']`, "Invalid escape character U+0022 '\"' in string", 2},
+		{`['\u12']`, "Invalid \\u escape in string", 2},
+	}
+	for _, test := range invalid {
+		lexer := NewSliceLexer([]byte(test.input), JSON)
+		lexer.setOptions(all)
+		items := readerToArray(lexer)
+		last := items[len(items)-1]
+		if as.Equal(jsonError, last.typ, test.input) {
+			as.Equal(test.msg, string(last.val), test.input)
+			as.Equal(test.pos, last.pos, test.input)
+		}
+	}
+}
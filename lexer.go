@@ -14,6 +14,10 @@ type Item struct {
 	typ int
 	pos Pos // The starting position, in bytes, of this Item in the input string.
 	val []byte
+
+	line   int // 1-based line of pos
+	column int // 1-based column of pos, in bytes
+	size   int // bytes of input covered, if val was rewritten from lenient input
 }
 
 // Used by evaluator
@@ -31,6 +35,7 @@ type lexer interface {
 	ignore()
 	errorf(string, ...interface{}) stateFn
 	reset()
+	options() Options
 }
 
 type lex struct {
@@ -39,6 +44,9 @@ type lex struct {
 	item           Item
 	hasItem        bool
 	stack          intStack
+	line           int // lines started before the current token
+	lineStart      Pos // position of the first byte of the current line
+	opts           Options
 }
 
 func newLex(initial stateFn) lex {
@@ -47,19 +55,62 @@ func newLex(initial stateFn) lex {
 		currentStateFn: initial,
 		item:           Item{},
 		stack:          *newIntStack(),
+		line:           1,
 	}
 }
 
 func (i *Item) clone() *Item {
 	ic := Item{
-		typ: i.typ,
-		pos: i.pos,
-		val: make([]byte, len(i.val)),
+		typ:    i.typ,
+		pos:    i.pos,
+		val:    make([]byte, len(i.val)),
+		line:   i.line,
+		column: i.column,
+		size:   i.size,
 	}
 	copy(ic.val, i.val)
 	return &ic
 }
 
+// setOptions applies the options of an evaluation to the lexer
+func (l *lex) setOptions(o Options) {
+	l.opts = o
+}
+
+// options returns the options of the evaluation
+func (l *lex) options() Options {
+	return l.opts
+}
+
+// newline records a line break at pos
+func (l *lex) newline(pos Pos) {
+	l.line++
+	l.lineStart = pos + 1
+}
+
+func (l *lex) setItem(typ int, pos Pos, val []byte) {
+	l.item.typ = typ
+	l.item.pos = pos
+	l.item.val = val
+	l.item.line = l.line
+	l.item.column = int(pos-l.lineStart) + 1
+	l.item.size = 0
+	if l.opts.lenient() {
+		if n := normalizeLenient(typ, val); n != nil {
+			l.item.val = n
+			l.item.size = len(val)
+		}
+	}
+}
+
+// end returns the position after the input of the item
+func (i *Item) end() Pos {
+	if i.size > 0 {
+		return i.pos + Pos(i.size)
+	}
+	return i.pos + Pos(len(i.val))
+}
+
 func itemsDescription(items []Item, nameMap map[int]string) []string {
 	vals := make([]string, len(items))
 	for i, item := range items {
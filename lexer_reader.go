@@ -40,13 +40,11 @@ func (l *readerLexer) take() int {
 }
 
 func (l *readerLexer) takeString() error {
-	cur := l.take()
-	if cur != '"' {
-		return fmt.Errorf("Expected \" as start of string instead of %#U", cur)
+	quote := l.take()
+	if quote != '"' && (quote != '\'' || !l.opts.SingleQuotes) {
+		return fmt.Errorf("Expected \" as start of string instead of %#U", quote)
 	}
 
-	var previous byte
-looper:
 	for {
 		curByte, err := l.bufInput.ReadByte()
 		if err == io.EOF {
@@ -54,19 +52,27 @@ looper:
 		}
 		l.lexeme.WriteByte(curByte)
 
-		if curByte == '"' {
-			if previous != '\\' {
-				break looper
-			} else {
-				curByte, err = l.bufInput.ReadByte()
-				if err == io.EOF {
-					return errors.New("Unexpected EOF in string")
-				}
-				l.lexeme.WriteByte(curByte)
+		if curByte == '\\' {
+			// the escaped byte cannot end the string
+			curByte, err = l.bufInput.ReadByte()
+			if err == io.EOF {
+				return errors.New("Unexpected EOF in string")
 			}
+			l.lexeme.WriteByte(curByte)
+		} else if int(curByte) == quote {
+			break
+		}
+	}
+	check := checkJSONString
+	if quote == '\'' {
+		check = checkSingleQuoted
+	}
+	if l.opts.Strict || quote == '\'' {
+		if offset, err := check(l.lexeme.Bytes()); err != nil {
+			// point the error at the invalid byte
+			l.pos += Pos(offset)
+			return err
 		}
-
-		previous = curByte
 	}
 	return nil
 }
@@ -104,6 +110,9 @@ func (l *readerLexer) emit(t int) {
 	// ignore white space
 	for l.nextByte != eof {
 		if l.nextByte == ' ' || l.nextByte == '\t' || l.nextByte == '\r' || l.nextByte == '\n' {
+			if l.nextByte == '\n' {
+				l.newline(l.pos)
+			}
 			l.pos++
 			r, err := l.bufInput.ReadByte()
 			if err == io.EOF {
@@ -117,19 +126,26 @@ func (l *readerLexer) emit(t int) {
 	}
 }
 
-func (l *readerLexer) setItem(typ int, pos Pos, val []byte) {
-	l.item.typ = typ
-	l.item.pos = pos
-	l.item.val = val
-}
-
 func (l *readerLexer) ignore() {
+	for x, b := range l.lexeme.Bytes() {
+		if b == '\n' {
+			l.newline(l.pos + Pos(x))
+		}
+	}
 	l.pos += Pos(l.lexeme.Len())
 	l.lexeme.Reset()
 }
 
 func (l *readerLexer) next() (*Item, bool) {
 	l.lexeme.Reset()
+	if l.opts.Comments && l.currentStateFn != nil {
+		// comments may precede any token
+		if err := skipSpace(l); err != nil {
+			l.currentStateFn = l.errorf("%s", err)
+			l.hasItem = false
+			return &l.item, true
+		}
+	}
 	for {
 		if l.currentStateFn == nil {
 			break
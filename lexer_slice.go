@@ -37,31 +37,38 @@ func (l *sliceLexer) takeString() error {
 		return errors.New("End of file where string expected")
 	}
 
-	cur := int(l.input[curPos])
+	quote := int(l.input[curPos])
 	curPos++
-	if cur != '"' {
+	if quote != '"' && (quote != '\'' || !l.opts.SingleQuotes) {
 		l.pos = curPos
-		return fmt.Errorf("Expected \" as start of string instead of %#U", cur)
+		return fmt.Errorf("Expected \" as start of string instead of %#U", quote)
 	}
 
-	var previous int
-looper:
 	for {
 		if int(curPos) >= inputLen {
-			l.pos = curPos
+			l.pos = Pos(inputLen)
 			return errors.New("End of file where string expected")
 		}
-		cur := int(l.input[curPos])
+		cur := l.input[curPos]
 		curPos++
-		if cur == '"' {
-			if previous == noValue || previous != '\\' {
-				break looper
-			} else {
-				l.take()
-			}
+		if cur == '\\' {
+			// the escaped byte cannot end the string
+			curPos++
+		} else if int(cur) == quote {
+			break
+		}
+	}
+	check := checkJSONString
+	if quote == '\'' {
+		check = checkSingleQuoted
+	}
+	if l.opts.Strict || quote == '\'' {
+		if offset, err := check(l.input[l.pos:curPos]); err != nil {
+			// point the error at the invalid byte
+			l.start = l.pos + Pos(offset)
+			l.pos = l.start
+			return err
 		}
-
-		previous = cur
 	}
 	l.pos = curPos
 	return nil
@@ -82,6 +89,9 @@ func (l *sliceLexer) emit(t int) {
 	for int(l.pos) < len(l.input) {
 		r := l.input[l.pos]
 		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			if r == '\n' {
+				l.newline(l.pos)
+			}
 			l.pos++
 		} else {
 			break
@@ -91,17 +101,24 @@ func (l *sliceLexer) emit(t int) {
 	l.start = l.pos
 }
 
-func (l *sliceLexer) setItem(typ int, pos Pos, val []byte) {
-	l.item.typ = typ
-	l.item.pos = pos
-	l.item.val = val
-}
-
 func (l *sliceLexer) ignore() {
+	for x := l.start; x < l.pos; x++ {
+		if l.input[x] == '\n' {
+			l.newline(x)
+		}
+	}
 	l.start = l.pos
 }
 
 func (l *sliceLexer) next() (*Item, bool) {
+	if l.opts.Comments && l.currentStateFn != nil {
+		// comments may precede any token
+		if err := skipSpace(l); err != nil {
+			l.currentStateFn = l.errorf("%s", err)
+			l.hasItem = false
+			return &l.item, true
+		}
+	}
 	for {
 		if l.currentStateFn == nil {
 			break
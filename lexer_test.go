@@ -281,7 +281,7 @@ type lexTest struct {
 }
 
 func i(tokenType int) Item {
-	return Item{tokenType, 0, []byte{}}
+	return Item{typ: tokenType, val: []byte{}}
 }
 
 func typeIsEqual(i1, i2 []Item, printError bool) bool {
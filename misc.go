@@ -5,42 +5,58 @@ import (
 	"fmt"
 )
 
+// The take functions of numbers leave the byte an error is about unread,
+// so the error can point at it.
+
 func takeExponent(l lexer) error {
 	r := l.peek()
 	if r != 'e' && r != 'E' {
 		return nil
 	}
 	l.take()
-	r = l.take()
+	r = l.peek()
 	switch r {
 	case '+', '-':
+		l.take()
 		// Check digit immediately follows sign
 		if d := l.peek(); !(d >= '0' && d <= '9') {
-			return fmt.Errorf("Expected digit after numeric sign instead of %#U", d)
+			return fmt.Errorf("Expected digit after numeric sign instead of %s", describeByte(d))
 		}
 		takeDigits(l)
 	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 		takeDigits(l)
 	default:
-		return fmt.Errorf("Expected digit after 'e' instead of %#U", r)
+		return fmt.Errorf("Expected digit after 'e' instead of %s", describeByte(r))
 	}
 	return nil
 }
 
 func takeJSONNumeric(l lexer) error {
-	cur := l.take()
+	cur := l.peek()
 	switch cur {
 	case '-':
+		l.take()
 		// Check digit immediately follows sign
 		if d := l.peek(); !(d >= '0' && d <= '9') {
-			return fmt.Errorf("Expected digit after dash instead of %#U", d)
+			return fmt.Errorf("Expected digit after dash instead of %s", describeByte(d))
 		}
-		takeDigits(l)
+		cur = l.take()
 	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-		takeDigits(l)
+		l.take()
 	default:
-		return fmt.Errorf("Expected digit or dash instead of %#U", cur)
+		return fmt.Errorf("Expected digit or dash instead of %s", describeByte(cur))
 	}
+	if cur == '0' {
+		opts := l.options()
+		switch d := l.peek(); {
+		case (d == 'x' || d == 'X') && opts.HexNumbers:
+			l.take()
+			return takeHexDigits(l)
+		case d >= '0' && d <= '9' && opts.Strict:
+			return fmt.Errorf("Unexpected digit %s after leading zero", describeByte(d))
+		}
+	}
+	takeDigits(l)
 
 	// fraction or exponent
 	cur = l.peek()
@@ -49,7 +65,7 @@ func takeJSONNumeric(l lexer) error {
 		l.take()
 		// Check digit immediately follows period
 		if d := l.peek(); !(d >= '0' && d <= '9') {
-			return fmt.Errorf("Expected digit after '.' instead of %#U", d)
+			return fmt.Errorf("Expected digit after '.' instead of %s", describeByte(d))
 		}
 		takeDigits(l)
 		if err := takeExponent(l); err != nil {
@@ -64,6 +80,14 @@ func takeJSONNumeric(l lexer) error {
 	return nil
 }
 
+// describeByte names a byte of input for an error message
+func describeByte(c int) string {
+	if c == eof {
+		return "EOF"
+	}
+	return fmt.Sprintf("%#U", c)
+}
+
 func takeDigits(l lexer) {
 	for {
 		d := l.peek()
@@ -75,6 +99,76 @@ func takeDigits(l lexer) {
 	}
 }
 
+func takeHexDigits(l lexer) error {
+	if !isHexDigit(l.peek()) {
+		return fmt.Errorf("Expected hex digit after 0x instead of %s", describeByte(l.peek()))
+	}
+	for isHexDigit(l.peek()) {
+		l.take()
+	}
+	return nil
+}
+
+func isHexDigit(c int) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// takeUnquotedKey takes a key such as name or _id
+func takeUnquotedKey(l lexer) {
+	for isKeyStart(l.peek()) || l.peek() >= '0' && l.peek() <= '9' {
+		l.take()
+	}
+}
+
+func isKeyStart(c int) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$'
+}
+
+// skipSpace ignores whitespace and, when enabled, comments
+func skipSpace(l lexer) error {
+	comments := l.options().Comments
+	for {
+		switch r := l.peek(); {
+		case r == ' ' || r == '\t' || r == '\r' || r == '\n':
+			l.take()
+		case r == '/' && comments:
+			// errors point at the comment
+			l.ignore()
+			l.take()
+			switch r = l.take(); r {
+			case '/':
+				for r = l.peek(); r != '\n' && r != eof; r = l.peek() {
+					l.take()
+				}
+			case '*':
+				if !takeBlockComment(l) {
+					return errors.New("Unexpected EOF in comment")
+				}
+			default:
+				return fmt.Errorf("Expected '/' or '*' after '/' instead of %s", describeByte(r))
+			}
+		default:
+			l.ignore()
+			return nil
+		}
+	}
+}
+
+// takeBlockComment takes the rest of a /* */ comment
+func takeBlockComment(l lexer) bool {
+	for {
+		switch l.take() {
+		case eof:
+			return false
+		case '*':
+			if l.peek() == '/' {
+				l.take()
+				return true
+			}
+		}
+	}
+}
+
 // Only used at the very beginning of parsing. After that, the emit() function
 // automatically skips whitespace.
 func ignoreSpaceRun(l lexer) {
@@ -171,7 +265,7 @@ func getJsonTokenType(val []byte) (int, error) {
 		return jsonBracketLeft, nil
 	case 'n':
 		return jsonNull, nil
-	case 't', 'b':
+	case 't', 'f':
 		return jsonBool, nil
 	case '-', '+', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 		return jsonNumber, nil
@@ -1,9 +1,14 @@
 package jsonpath
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -13,151 +18,759 @@ const (
 	opTypeName
 	opTypeNameList
 	opTypeNameWild
+	opTypeUnion
+)
+
+// dialects of the path syntax
+const (
+	dialectDefault = iota
+	dialectRFC9535
+	dialectJayway
 )
 
 type Path struct {
 	stringValue     string
 	operators       []*operator
 	captureEndValue bool
+	dialect         int
+	exactNumbers    bool
 }
 
 type operator struct {
-	typ         int
-	indexStart  int
-	indexEnd    int
-	hasIndexEnd bool
-	keyStrings  map[string]struct{}
+	typ           int
+	indexStart    int
+	indexEnd      int
+	indexStep     int
+	hasIndexStart bool
+	hasIndexEnd   bool
+	keyStrings    map[string]struct{}
+	union         []*operator
+
+	// descendant operators match at any depth below the previous operator
+	descendant     bool
+	descendantPath *Path
 
 	whereClauseBytes []byte
 	dependentPaths   []*Path
+	unfiltered       *operator // selectors beside the filters of a bracket
 	whereClause      []Item
+	rootPaths        []string // $ paths the where clause depends on, also in nested filters
+
+	// literals and functions of the where clause, compiled when the path is
+	// parsed
+	regexps   map[string]*regexp.Regexp
+	lists     map[string][]interface{}
+	functions map[string]*filterFunction
+	compiled  compiledFilter
+	exact     bool // numbers are *big.Rat instead of float64
+	usesNodes bool // a function takes all the nodes of a path
+}
+
+func genIndexKey(tr tokenReader, dialect int) (*operator, error) {
+	selectors := make([]*operator, 0, 1)
+	for {
+		k, t, err := genSelector(tr, dialect)
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, k)
+		if t.typ == pathBracketRight {
+			break
+		}
+	}
+
+	if len(selectors) == 1 {
+		return selectors[0], nil
+	}
+	return genFilterUnion(selectors)
 }
 
-func genIndexKey(tr tokenReader) (*operator, error) {
+// genFilterUnion combines the selectors of one bracket that may hold filters.
+// The filters become one where clause that selects a node when any of them
+// does, and nodes matched by the other selectors are selected without it.
+func genFilterUnion(selectors []*operator) (*operator, error) {
+	var filters [][]byte
+	others := make([]*operator, 0, len(selectors))
+	for _, k := range selectors {
+		if k.whereClauseBytes != nil {
+			filters = append(filters, k.whereClauseBytes)
+		} else {
+			others = append(others, k)
+		}
+	}
+	if filters == nil {
+		return genUnion(selectors), nil
+	}
+
+	k := newChildWildcard()
+	if len(filters) == 1 {
+		k.whereClauseBytes = filters[0]
+	} else {
+		// in parentheses as a whole, like the filter of the default syntax
+		expression := []byte{'('}
+		for x, f := range filters {
+			if x > 0 {
+				expression = append(expression, " || "...)
+			}
+			expression = append(expression, '(')
+			expression = append(expression, f...)
+			expression = append(expression, ')')
+		}
+		k.whereClauseBytes = append(expression, ')')
+	}
+	if len(others) > 0 {
+		k.unfiltered = genUnion(others)
+		if k.unfiltered.windowed() {
+			return nil, errors.New("Filters cannot be combined with selectors counting from the end of an array")
+		}
+	}
+	return k, nil
+}
+
+// genSelector reads one selector within brackets and returns it along with
+// the ] or , that ends it
+func genSelector(tr tokenReader, dialect int) (*operator, *Item, error) {
 	k := &operator{}
 	var t *Item
 	var ok bool
 	if t, ok = tr.next(); !ok {
-		return nil, errors.New("Expected number, key, or *, but got none")
+		return nil, nil, errors.New("Expected number, key, or *, but got none")
 	}
 
 	switch t.typ {
 	case pathWildcard:
 		k.typ = opTypeIndexWild
 		k.indexStart = 0
+		if dialect != dialectDefault {
+			k = newChildWildcard()
+		}
 		if t, ok = tr.next(); !ok {
-			return nil, errors.New("Expected ] after *, but got none")
+			return nil, nil, errors.New("Expected ] after *, but got none")
 		}
-		if t.typ != pathBracketRight {
-			return nil, fmt.Errorf("Expected ] after * instead of %q", t.val)
+		if t.typ != pathBracketRight && t.typ != pathComma {
+			return nil, nil, fmt.Errorf("Expected ] after * instead of %q", t.val)
 		}
 	case pathIndex:
 		v, err := strconv.Atoi(string(t.val))
 		if err != nil {
-			return nil, fmt.Errorf("Could not parse %q into int64", t.val)
+			return nil, nil, fmt.Errorf("Could not parse %q into int64", t.val)
 		}
 		k.indexStart = v
-		k.indexEnd = v
-		k.hasIndexEnd = true
+		k.hasIndexStart = true
 
 		if t, ok = tr.next(); !ok {
-			return nil, errors.New("Expected number or *, but got none")
+			return nil, nil, errors.New("Expected number or *, but got none")
 		}
 		switch t.typ {
 		case pathIndexRange:
-			if t, ok = tr.next(); !ok {
-				return nil, errors.New("Expected number or *, but got none")
-			}
-			switch t.typ {
-			case pathIndex:
-				v, err := strconv.Atoi(string(t.val))
-				if err != nil {
-					return nil, fmt.Errorf("Could not parse %q into int64", t.val)
-				}
-				k.indexEnd = v - 1
-				k.hasIndexEnd = true
-
-				if t, ok = tr.next(); !ok || t.typ != pathBracketRight {
-					return nil, errors.New("Expected ], but got none")
-				}
-			case pathBracketRight:
-				k.hasIndexEnd = false
-			default:
-				return nil, fmt.Errorf("Unexpected value within brackets after index: %q", t.val)
+			if t, err = genIndexRange(k, tr); err != nil {
+				return nil, nil, err
 			}
-
-			k.typ = opTypeIndexRange
-		case pathBracketRight:
+		case pathBracketRight, pathComma:
 			k.typ = opTypeIndex
 		default:
-			return nil, fmt.Errorf("Unexpected value within brackets after index: %q", t.val)
+			return nil, nil, fmt.Errorf("Unexpected value within brackets after index: %q", t.val)
+		}
+	case pathIndexRange:
+		var err error
+		if t, err = genIndexRange(k, tr); err != nil {
+			return nil, nil, err
 		}
 	case pathKey:
-		k.keyStrings = map[string]struct{}{string(t.val[1 : len(t.val)-1]): struct{}{}}
+		key, err := unquoteString(t.val)
+		if err != nil {
+			return nil, nil, err
+		}
+		k.keyStrings = map[string]struct{}{key: struct{}{}}
 		k.typ = opTypeName
 
-		if t, ok = tr.next(); !ok || t.typ != pathBracketRight {
+		if t, ok = tr.next(); !ok || (t.typ != pathBracketRight && t.typ != pathComma) {
+			return nil, nil, errors.New("Expected ], but got none")
+		}
+	case pathWhere:
+		if t, ok = tr.next(); !ok || t.typ != pathExpression {
+			return nil, nil, errors.New("Expected filter expression after ?")
+		}
+		k = newChildWildcard()
+		k.whereClauseBytes = t.val
+
+		if t, ok = tr.next(); !ok || (t.typ != pathBracketRight && t.typ != pathComma) {
+			return nil, nil, errors.New("Expected ] after filter expression")
+		}
+	default:
+		return nil, nil, fmt.Errorf("Unexpected value within brackets: %q", t.val)
+	}
+
+	return k, t, nil
+}
+
+// newChildWildcard returns an operator matching every member and element
+func newChildWildcard() *operator {
+	return &operator{typ: opTypeUnion, union: []*operator{{typ: opTypeNameWild}, {typ: opTypeIndexWild}}}
+}
+
+// genIndexRange reads the end and step of a slice after its first colon
+func genIndexRange(k *operator, tr tokenReader) (*Item, error) {
+	k.typ = opTypeIndexRange
+	k.indexStep = 1
+
+	t, ok := tr.next()
+	if !ok {
+		return nil, errors.New("Expected number or ], but got none")
+	}
+	if t.typ == pathIndex {
+		v, err := strconv.Atoi(string(t.val))
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse %q into int64", t.val)
+		}
+		k.indexEnd = v
+		k.hasIndexEnd = true
+
+		if t, ok = tr.next(); !ok {
 			return nil, errors.New("Expected ], but got none")
 		}
+	}
+	if t.typ == pathIndexRange {
+		if t, ok = tr.next(); !ok {
+			return nil, errors.New("Expected number or ], but got none")
+		}
+		if t.typ == pathIndex {
+			v, err := strconv.Atoi(string(t.val))
+			if err != nil {
+				return nil, fmt.Errorf("Could not parse %q into int64", t.val)
+			}
+			k.indexStep = v
+
+			if t, ok = tr.next(); !ok {
+				return nil, errors.New("Expected ], but got none")
+			}
+		}
+	}
+	if t.typ != pathBracketRight && t.typ != pathComma {
+		return nil, fmt.Errorf("Unexpected value within brackets after index: %q", t.val)
+	}
+	return t, nil
+}
+
+// genUnion combines the selectors of one bracket. Names only become a name
+// list, anything else a union that matches when any of its selectors does.
+func genUnion(selectors []*operator) *operator {
+	names := &operator{typ: opTypeNameList, keyStrings: map[string]struct{}{}}
+	for _, k := range selectors {
+		if k.typ != opTypeName {
+			return &operator{typ: opTypeUnion, union: selectors}
+		}
+		for key := range k.keyStrings {
+			names.keyStrings[key] = struct{}{}
+		}
+	}
+	return names
+}
+
+// windowed operators select from the end of an array, so they can only be
+// decided once the array has been read to its end
+func (op *operator) windowed() bool {
+	switch op.typ {
+	case opTypeIndex:
+		return op.indexStart < 0
+	case opTypeIndexRange:
+		return op.indexStep < 0 || op.indexStart < 0 || (op.hasIndexEnd && op.indexEnd < 0)
+	case opTypeUnion:
+		for _, u := range op.union {
+			if u.windowed() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sliceBounds returns the normalized RFC 9535 bounds of a slice over an
+// array of the given length. Selected indexes run from first towards last,
+// excluding last, in steps of indexStep.
+func (op *operator) sliceBounds(length int) (first, last int) {
+	normalize := func(i int) int {
+		if i < 0 {
+			return length + i
+		}
+		return i
+	}
+	clamp := func(i, lower, upper int) int {
+		if i < lower {
+			return lower
+		} else if i > upper {
+			return upper
+		}
+		return i
+	}
+
+	if op.indexStep >= 0 {
+		first, last = 0, length
+		if op.hasIndexStart {
+			first = clamp(normalize(op.indexStart), 0, length)
+		}
+		if op.hasIndexEnd {
+			last = clamp(normalize(op.indexEnd), 0, length)
+		}
+		return first, last
+	}
+
+	first, last = length-1, -1
+	if op.hasIndexStart {
+		first = clamp(normalize(op.indexStart), -1, length-1)
+	}
+	if op.hasIndexEnd {
+		last = clamp(normalize(op.indexEnd), -1, length-1)
+	}
+	return first, last
+}
+
+// sliceSelects reports whether a slice over an array of the given length
+// selects index
+func (op *operator) sliceSelects(index, length int) bool {
+	first, last := op.sliceBounds(length)
+	switch {
+	case op.indexStep > 0:
+		return index >= first && index < last && (index-first)%op.indexStep == 0
+	case op.indexStep < 0:
+		return index <= first && index > last && (first-index)%(-op.indexStep) == 0
+	}
+	return false
+}
+
+// selectIndex reports whether the element at index is selected by a windowed
+// operator when at least length elements exist, and whether that is already
+// known. Once final is set, length is the length of the array.
+func (op *operator) selectIndex(index, length int, final bool) (selected bool, known bool) {
+	switch op.typ {
+	case opTypeUnion:
+		known = true
+		for _, u := range op.union {
+			var s, k bool
+			if u.windowed() {
+				s, k = u.selectIndex(index, length, final)
+			} else {
+				s, k = itemMatchOperator(index, nil, u), true
+			}
+			if s && k {
+				return true, true
+			}
+			known = known && k
+		}
+		return false, known
+	case opTypeIndex:
+		if final {
+			return index == length+op.indexStart, true
+		}
+		// The array only grows, so elements fall out of reach from the end
+		return false, index < length+op.indexStart
+	}
+
+	if final {
+		return op.sliceSelects(index, length), true
+	}
+	if op.indexStep < 0 {
+		// Reversed slices are only released at the end of the array, but
+		// elements past an explicit bound can be dropped early
+		if (op.hasIndexStart && op.indexStart >= 0 && index > op.indexStart) ||
+			(op.hasIndexEnd && op.indexEnd >= 0 && index <= op.indexEnd) {
+			return false, true
+		}
+		return false, false
+	}
+
+	if op.indexStep == 0 {
+		return false, true
+	}
+
+	known = true
+	switch {
+	case op.indexStart >= 0:
+		if index < op.indexStart || (index-op.indexStart)%op.indexStep != 0 {
+			return false, true
+		}
+	case index < length+op.indexStart:
+		return false, true
 	default:
-		return nil, fmt.Errorf("Unexpected value within brackets: %q", t.val)
+		known = false
 	}
 
-	return k, nil
+	switch {
+	case !op.hasIndexEnd:
+	case op.indexEnd >= 0:
+		if index >= op.indexEnd {
+			return false, true
+		}
+	case index < length+op.indexEnd:
+	default:
+		known = false
+	}
+	return known, known
 }
 
 func parsePath(pathString string) (*Path, error) {
-	lexer := NewSliceLexer([]byte(pathString), PATH)
-	p, err := tokensToOperators(lexer)
+	return genPath(pathString, dialectDefault, false)
+}
+
+// ExactNumbers makes the filters of the path compare and compute numbers
+// exactly with math/big instead of as float64, so integers beyond 2^53 and
+// decimals such as 0.1 keep their value.
+func (p *Path) ExactNumbers() error {
+	exact, err := genPath(p.stringValue, p.dialect, true)
+	if err != nil {
+		return err
+	}
+	*p = *exact
+	return nil
+}
+
+func genPath(pathString string, dialect int, exact bool) (*Path, error) {
+	initial := PATH
+	switch dialect {
+	case dialectRFC9535:
+		initial = lexPathRFC9535
+	case dialectJayway:
+		initial = lexPathJayway
+	}
+	lexer := NewSliceLexer([]byte(pathString), initial)
+	p, err := tokensToOperators(lexer, dialect)
 	if err != nil {
 		return nil, err
 	}
 
 	p.stringValue = pathString
+	p.dialect = dialect
+	p.exactNumbers = exact
+	if dialect != dialectDefault {
+		p.captureEndValue = true
+	}
 
 	//Generate dependent paths
 	for _, op := range p.operators {
 		if len(op.whereClauseBytes) > 0 {
-			var err error
-			trimmed := op.whereClauseBytes[1 : len(op.whereClauseBytes)-1]
-			whereLexer := NewSliceLexer(trimmed, EXPRESSION)
-			items := readerToArray(whereLexer)
-			if errItem, found := findErrors(items); found {
-				return nil, errors.New(string(errItem.val))
+			op.exact = exact
+			if err := genWhereClause(op, dialect); err != nil {
+				return nil, err
 			}
+		}
+	}
 
-			// transform expression into postfix form
-			op.whereClause, err = infixToPostFix(items[:len(items)-1]) // trim EOF
+	// Generate the paths that descendant matches continue with
+	for x, op := range p.operators {
+		if op.descendant {
+			child := *op
+			child.descendant = false
+			child.descendantPath = nil
+			op.descendantPath = &Path{
+				stringValue:     pathString,
+				operators:       append([]*operator{&child}, p.operators[x+1:]...),
+				captureEndValue: p.captureEndValue,
+				dialect:         dialect,
+				exactNumbers:    exact,
+			}
+		}
+	}
+	return p, nil
+}
+
+func genWhereClause(op *operator, dialect int) error {
+	var err error
+	expression := op.whereClauseBytes
+	precedence := opa
+	switch dialect {
+	case dialectDefault:
+		expression = expression[1 : len(expression)-1]
+	case dialectJayway:
+		if err := checkJaywayFilter(expression); err != nil {
+			return err
+		}
+	}
+	whereLexer := NewSliceLexer(expression, EXPRESSION)
+	items := readerToArray(whereLexer)
+	if errItem, found := findErrors(items); found {
+		return errors.New(string(errItem.val))
+	}
+	items = items[:len(items)-1] // trim EOF
+
+	// strings are compared in their JSON form
+	for x, item := range items {
+		if item.typ == exprString && (item.val[0] == '\'' || dialect != dialectDefault) {
+			s, err := unquoteString(item.val)
 			if err != nil {
-				return nil, err
+				return err
+			}
+			items[x].val = quoteString(s)
+		}
+	}
+	if dialect != dialectDefault {
+		if items, err = checkRFC9535Expression(items, dialect); err != nil {
+			return err
+		}
+		precedence = opaRFC9535
+	}
+
+	// transform expression into postfix form
+	op.whereClause, err = infixToPostFixPrecedence(items, precedence)
+	if err != nil {
+		return err
+	}
+	if err = checkFunctions(op); err != nil {
+		return err
+	}
+	markExistenceTests(op.whereClause)
+	if dialect == dialectDefault {
+		err = checkTypes(op)
+	} else {
+		err = checkRFC9535Types(op, dialect)
+	}
+	if err != nil {
+		return err
+	}
+	if err = foldConstants(op, dialect); err != nil {
+		return err
+	}
+	op.dependentPaths = make([]*Path, 0)
+	// parse all paths in expression
+	for _, item := range op.whereClause {
+		switch item.typ {
+		case exprRegex:
+			if op.regexps == nil {
+				op.regexps = make(map[string]*regexp.Regexp)
+			}
+			if op.regexps[string(item.val)], err = compileRegex(item.val); err != nil {
+				return err
+			}
+		case exprList:
+			if op.lists == nil {
+				op.lists = make(map[string][]interface{})
+			}
+			if op.lists[string(item.val)], err = parseList(item.val, dialect, op.exact); err != nil {
+				return err
+			}
+		}
+		if item.typ == exprPathNodes {
+			op.usesNodes = true
+		}
+		if item.typ == exprPath || item.typ == exprPathExists || item.typ == exprPathValue || item.typ == exprPathNodes {
+			p, err := genPath(string(item.val), dialect, op.exact)
+			if err != nil {
+				return err
+			}
+			op.dependentPaths = append(op.dependentPaths, p)
+			if p.isRoot() {
+				op.rootPaths = append(op.rootPaths, p.stringValue)
+			}
+			for _, o := range p.operators {
+				op.rootPaths = append(op.rootPaths, o.rootPaths...)
+			}
+		}
+	}
+	op.compiled, err = compileFilter(op.whereClause, dialect, op)
+	return err
+}
+
+// isRoot reports whether a path in a where clause starts at the root of the
+// document instead of the current node
+func (p *Path) isRoot() bool {
+	return strings.HasPrefix(p.stringValue, "$")
+}
+
+// singular reports whether the path selects at most one value
+func (p *Path) singular() bool {
+	for _, op := range p.operators {
+		if op.descendant || op.whereClauseBytes != nil {
+			return false
+		}
+		if op.typ != opTypeIndex && !(op.typ == opTypeName && len(op.keyStrings) == 1) {
+			return false
+		}
+	}
+	return true
+}
+
+// regexp returns the compiled regular expression literal val
+func (op *operator) regexp(val []byte) (*regexp.Regexp, error) {
+	if op != nil {
+		if re, ok := op.regexps[string(val)]; ok {
+			return re, nil
+		}
+	}
+	return compileRegex(val)
+}
+
+// list returns the values of the list literal val
+func (op *operator) list(val []byte, dialect int) ([]interface{}, error) {
+	if op != nil {
+		if list, ok := op.lists[string(val)]; ok {
+			return list, nil
+		}
+	}
+	return parseList(val, dialect, op.exactNumbers())
+}
+
+// exactNumbers reports whether the where clause uses exact numbers
+func (op *operator) exactNumbers() bool {
+	return op != nil && op.exact
+}
+
+// function returns the function called by an exprFunc item
+func (op *operator) function(val []byte) (*filterFunction, error) {
+	name := string(val[:len(val)-1]) // trim (
+	if op != nil {
+		if f, ok := op.functions[name]; ok {
+			return f, nil
+		}
+	}
+	return lookupFunction(name)
+}
+
+// checkFunctions resolves the functions of a where clause and checks their
+// arguments. Paths given directly as arguments are marked with the type of
+// their parameter.
+func checkFunctions(op *operator) error {
+	postFix := op.whereClause
+	_, err := walkPostFix(postFix, func(x int, args []int) error {
+		if postFix[x].typ != exprFunc {
+			return nil
+		}
+		f, err := op.function(postFix[x].val)
+		if err != nil {
+			return err
+		}
+		if len(args) != len(f.signature.Params) {
+			return fmt.Errorf("Function %s() takes %d arguments, not %d", f.name, len(f.signature.Params), len(args))
+		}
+		for k, a := range args {
+			arg := &postFix[a]
+			switch f.signature.Params[k] {
+			case NodesType:
+				if arg.typ != exprPath {
+					return fmt.Errorf("Argument %d of %s() must be a path", k+1, f.name)
+				}
+				arg.typ = exprPathNodes
+			case ValueType:
+				if arg.typ == exprPath {
+					arg.typ = exprPathValue
+				}
+			case LogicalType:
+				if arg.typ == exprPath {
+					arg.typ = exprPathExists
+				}
+			}
+		}
+		if op.functions == nil {
+			op.functions = make(map[string]*filterFunction)
+		}
+		op.functions[f.name] = f
+		return nil
+	})
+	return err
+}
+
+// compileRegex compiles a literal such as /ab+c/i. The flags i, m and s have
+// their Go meaning.
+func compileRegex(val []byte) (*regexp.Regexp, error) {
+	end := bytes.LastIndexByte(val, '/')
+	if len(val) < 2 || val[0] != '/' || end < 1 {
+		return nil, fmt.Errorf("Invalid regular expression %s", val)
+	}
+	pattern := string(val[1:end])
+	if flags := val[end+1:]; len(flags) > 0 {
+		for _, f := range flags {
+			if f != 'i' && f != 'm' && f != 's' {
+				return nil, fmt.Errorf("Unsupported regular expression flag %q", f)
+			}
+		}
+		pattern = "(?" + string(flags) + ")" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid regular expression %s: %s", val, err.Error())
+	}
+	return re, nil
+}
+
+// parseList decodes a list literal such as ['a', "b", 1] into filter values
+// of the dialect
+func parseList(val []byte, dialect int, exact bool) ([]interface{}, error) {
+	var normalized bytes.Buffer
+	for x := 0; x < len(val); x++ {
+		if val[x] != '\'' && val[x] != '"' {
+			normalized.WriteByte(val[x])
+			continue
+		}
+		end := x + 1
+		for ; end < len(val) && val[end] != val[x]; end++ {
+			if val[end] == '\\' {
+				end++
 			}
-			op.dependentPaths = make([]*Path, 0)
-			// parse all paths in expression
-			for _, item := range op.whereClause {
-				if item.typ == exprPath {
-					p, err := parsePath(string(item.val))
-					if err != nil {
-						return nil, err
-					}
-					op.dependentPaths = append(op.dependentPaths, p)
+		}
+		if end >= len(val) {
+			return nil, errors.New("Unterminated string in list")
+		}
+		s, err := unquoteString(val[x : end+1])
+		if err != nil {
+			return nil, err
+		}
+		normalized.Write(quoteString(s))
+		x = end
+	}
+
+	var values []interface{}
+	d := json.NewDecoder(&normalized)
+	d.UseNumber()
+	if err := d.Decode(&values); err != nil || d.More() {
+		return nil, fmt.Errorf("Invalid list %s", val)
+	}
+	for x, v := range values {
+		switch v := v.(type) {
+		case json.Number:
+			if exact {
+				r, ok := new(big.Rat).SetString(v.String())
+				if !ok {
+					return nil, fmt.Errorf("Invalid list %s", val)
 				}
+				values[x] = r
+			} else {
+				f, err := v.Float64()
+				if err != nil {
+					return nil, fmt.Errorf("Invalid list %s", val)
+				}
+				values[x] = f
 			}
+		case string:
+			if dialect == dialectDefault {
+				values[x] = quoteString(v)
+			}
+		case []interface{}, map[string]interface{}:
+			return nil, fmt.Errorf("Lists may only hold strings, numbers, booleans and null: %s", val)
 		}
 	}
-	return p, nil
+	return values, nil
 }
 
-func tokensToOperators(tr tokenReader) (*Path, error) {
+func tokensToOperators(tr tokenReader, dialect int) (*Path, error) {
 	q := &Path{
 		stringValue:     "",
 		captureEndValue: false,
 		operators:       make([]*operator, 0),
 	}
+	descendant := false
+	var prevEnd Pos
+	add := func(op *operator) {
+		op.descendant = descendant
+		descendant = false
+		q.operators = append(q.operators, op)
+	}
 	for {
 		p, ok := tr.next()
 		if !ok {
 			break
 		}
+		if dialect == dialectRFC9535 && (p.typ == pathKey || p.typ == pathWildcard) && p.pos != prevEnd {
+			return nil, fmt.Errorf("Unexpected whitespace before %q at %d", p.val, p.pos)
+		}
+		prevEnd = p.pos + Pos(len(p.val))
 		switch p.typ {
 		case pathRoot:
 			if len(q.operators) != 0 {
@@ -171,27 +784,47 @@ func tokensToOperators(tr tokenReader) (*Path, error) {
 			continue
 		case pathPeriod:
 			continue
+		case pathDescendant:
+			if descendant {
+				return nil, fmt.Errorf("Unexpected .. at %d", p.pos)
+			}
+			descendant = true
+			continue
 		case pathBracketLeft:
-			k, err := genIndexKey(tr)
+			k, err := genIndexKey(tr, dialect)
 			if err != nil {
 				return nil, err
 			}
-			q.operators = append(q.operators, k)
+			add(k)
 		case pathKey:
 			keyName := p.val
 			if len(p.val) == 0 {
 				return nil, fmt.Errorf("Key length is zero at %d", p.pos)
 			}
-			if p.val[0] == '"' && p.val[len(p.val)-1] == '"' {
-				keyName = p.val[1 : len(p.val)-1]
+			if len(p.val) > 1 && p.val[0] == '"' && p.val[len(p.val)-1] == '"' {
+				key, err := unquoteString(p.val)
+				if err != nil {
+					return nil, err
+				}
+				keyName = []byte(key)
 			}
-			q.operators = append(q.operators, &operator{typ: opTypeName, keyStrings: map[string]struct{}{string(keyName): struct{}{}}})
+			add(&operator{typ: opTypeName, keyStrings: map[string]struct{}{string(keyName): struct{}{}}})
 		case pathWildcard:
-			q.operators = append(q.operators, &operator{typ: opTypeNameWild})
+			if dialect != dialectDefault {
+				add(newChildWildcard())
+				continue
+			}
+			add(&operator{typ: opTypeNameWild})
 		case pathValue:
+			if descendant {
+				return nil, errors.New("Expected key, index or wildcard after ..")
+			}
 			q.captureEndValue = true
 		case pathWhere:
 		case pathExpression:
+			if descendant {
+				return nil, errors.New("Expected key, index or wildcard after ..")
+			}
 			if len(q.operators) == 0 {
 				return nil, errors.New("Cannot add where clause on last key")
 			}
@@ -204,5 +837,8 @@ func tokensToOperators(tr tokenReader) (*Path, error) {
 			return q, errors.New(string(p.val))
 		}
 	}
+	if descendant {
+		return nil, errors.New("Expected key, index or wildcard after ..")
+	}
 	return q, nil
 }
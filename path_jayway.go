@@ -0,0 +1,122 @@
+package jsonpath
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Jayway filter operators and path functions that have no equivalent here
+var (
+	jaywayFunction     = regexp.MustCompile(`\.([A-Za-z_][A-Za-z0-9_]*)\(`)
+	jaywayWordOperator = regexp.MustCompile(`(^|[\s)])(nin|subsetof|anyof|noneof|size|empty)(\s|\(|\[|$)`)
+)
+
+// ParsePathsJayway parses paths written in the Goessner/Jayway JsonPath
+// syntax, such as `$.store.book[?(@.price < 10)].title`. Like Jayway, they
+// always return the matched values.
+func ParsePathsJayway(pathStrings ...string) ([]*Path, error) {
+	paths := make([]*Path, len(pathStrings))
+	for x, p := range pathStrings {
+		path, err := parsePathJayway(p)
+		if err != nil {
+			return nil, err
+		}
+		paths[x] = path
+	}
+	return paths, nil
+}
+
+func parsePathJayway(pathString string) (*Path, error) {
+	pathString = strings.TrimSpace(pathString)
+	if !strings.HasPrefix(pathString, "$") {
+		return nil, errors.New("Expected $ at start of path")
+	}
+	if m := jaywayFunction.FindStringSubmatch(stripBrackets(pathString)); m != nil {
+		return nil, fmt.Errorf("Function %s() is not supported", m[1])
+	}
+	return genPath(pathString, dialectJayway, false)
+}
+
+// The Jayway dialect shares the RFC 9535 lexer states. The dialect is kept
+// on the state stack so member names can be read the Jayway way.
+func lexPathJayway(l lexer, state *intStack) stateFn {
+	state.push(dialectJayway)
+	return lexPathRFC9535
+}
+
+// lexJaywayMemberName reads a member name after a period, which may hold any
+// character up to the next segment
+func lexJaywayMemberName(l lexer, state *intStack) stateFn {
+	cur := l.peek()
+	if cur == '*' {
+		l.take()
+		l.emit(pathWildcard)
+		return lexRFC9535Segment
+	}
+
+	taken := false
+	for cur != eof && cur != '.' && cur != '[' && cur != '(' && cur != ' ' {
+		l.take()
+		taken = true
+		cur = l.peek()
+	}
+	if !taken {
+		return l.errorf("Expected member name or * instead of %s", describeByte(cur))
+	}
+	l.emit(pathKey)
+	return lexRFC9535Segment
+}
+
+// checkJaywayFilter reports Jayway filter constructs that cannot be
+// translated
+func checkJaywayFilter(expression []byte) error {
+	e := stripQuoted(string(expression))
+	if m := jaywayFunction.FindStringSubmatch(e); m != nil {
+		return fmt.Errorf("Function %s() is not supported", m[1])
+	}
+	if m := jaywayWordOperator.FindStringSubmatch(e); m != nil {
+		return fmt.Errorf("Filter operator %s is not supported", m[2])
+	}
+	return nil
+}
+
+// stripQuoted blanks out quoted strings so their contents are not mistaken
+// for syntax
+func stripQuoted(s string) string {
+	b := []byte(s)
+	for x := 0; x < len(b); x++ {
+		if b[x] != '\'' && b[x] != '"' {
+			continue
+		}
+		quote := b[x]
+		for x++; x < len(b) && b[x] != quote; x++ {
+			if b[x] == '\\' && x+1 < len(b) {
+				b[x] = ' '
+				x++
+			}
+			b[x] = ' '
+		}
+	}
+	return string(b)
+}
+
+// stripBrackets removes bracketed selectors and quoted strings, leaving the
+// dotted segments of a path
+func stripBrackets(s string) string {
+	s = stripQuoted(s)
+	var sb strings.Builder
+	depth := 0
+	for _, c := range s {
+		switch {
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case depth == 0:
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,86 @@
+package jsonpath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var jaywayTests = []struct {
+	path     string
+	expected []string
+}{
+	{`$.store.book[*].author`, []string{`"Rees"`, `"Waugh"`, `"Melville"`}},
+	{`$..author`, []string{`"Rees"`, `"Waugh"`, `"Melville"`}},
+	{`$.store..price`, []string{`8.95`, `12.99`, `8.99`, `19.95`}},
+	{`$['store']['bicycle'].color`, []string{`"red"`}},
+	{`$.store.*.color`, []string{`"red"`}},
+	{`$..book[-1].author`, []string{`"Melville"`}},
+	{`$..book[0,1].price`, []string{`8.95`, `12.99`}},
+	{`$..book[?(@.isbn)].author`, []string{`"Melville"`}},
+	{`$.store.book[?(@.price < 10)].author`, []string{`"Rees"`, `"Melville"`}},
+	{`$..book[?(@.category == 'fiction' && @.price > 10)].author`, []string{`"Waugh"`}},
+	{`$.store.bicycle.first-gear`, []string{`1`}},
+	{`$..book[?(@.author =~ /.*REES/i)].price`, []string{`8.95`}},
+	{`$..book[?(@.price =~ /8/)].author`, []string{}},
+	{`$..book[?(@.category in ['reference', 'poetry'])].author`, []string{`"Rees"`}},
+	{`$..book[?(@.price in [12.99, 8.99])].author`, []string{`"Waugh"`, `"Melville"`}},
+	{`$..book[?(@.price < $.expensive)].author`, []string{`"Rees"`, `"Melville"`}},
+}
+
+const jaywayJSON = `{"store":{
+	"book":[
+		{"category":"reference","author":"Rees","price":8.95},
+		{"category":"fiction","author":"Waugh","price":12.99},
+		{"category":"fiction","author":"Melville","isbn":"0-553-21311-3","price":8.99}
+	],
+	"bicycle":{"color":"red","price":19.95,"first-gear":1}
+},"expensive":10}`
+
+func TestJaywayPaths(t *testing.T) {
+	as := assert.New(t)
+
+	for _, test := range jaywayTests {
+		paths, err := ParsePathsJayway(test.path)
+		if !as.NoError(err, test.path) {
+			continue
+		}
+		eval, err := EvalPathsInBytes([]byte(jaywayJSON), paths)
+		as.NoError(err)
+
+		actual := make([]string, 0)
+		for {
+			r, ok := eval.Next()
+			if !ok {
+				break
+			}
+			actual = append(actual, string(r.Value))
+		}
+		as.NoError(eval.Error)
+		as.EqualValues(test.expected, actual, test.path)
+	}
+}
+
+func TestJaywayUnsupported(t *testing.T) {
+	as := assert.New(t)
+
+	for path, msg := range map[string]string{
+		`$..book.length()`:                       `Function length() is not supported`,
+		`$..book[?(@.title.length() > 3)]`:       `Function length() is not supported`,
+		`$..book[?(@.category nin ['fiction'])]`: `Filter operator nin is not supported`,
+		`$..book[?(@.author contains 'W')]`:      `Operator contains is not supported in RFC 9535 or Jayway filters`,
+		`$..book[?(@.tags size 2)]`:              `Filter operator size is not supported`,
+		`$..book[?(@.price + 1 < 10)]`:           `Operator + is not supported in RFC 9535 or Jayway filters`,
+		`store.book`:                             `Expected $ at start of path`,
+		`$..`:                                    `Expected member name or * instead of EOF`,
+	} {
+		_, err := ParsePathsJayway(path)
+		if as.Error(err, path) {
+			as.EqualError(err, msg)
+		}
+	}
+
+	// Words inside strings are not operators
+	_, err := ParsePathsJayway(`$..book[?(@.category == 'in =~ size')]`)
+	as.NoError(err)
+}
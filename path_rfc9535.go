@@ -0,0 +1,575 @@
+package jsonpath
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RFC 9535 integers must be exactly representable as IEEE 754 doubles
+const rfc9535MaxInt = 1<<53 - 1
+
+// ParsePathsRFC9535 parses paths written in the RFC 9535 JSONPath syntax.
+// They always return the matched values; there is no + operator.
+func ParsePathsRFC9535(pathStrings ...string) ([]*Path, error) {
+	paths := make([]*Path, len(pathStrings))
+	for x, p := range pathStrings {
+		path, err := parsePathRFC9535(p)
+		if err != nil {
+			return nil, err
+		}
+		paths[x] = path
+	}
+	return paths, nil
+}
+
+func parsePathRFC9535(pathString string) (*Path, error) {
+	if !strings.HasPrefix(pathString, "$") {
+		return nil, errors.New("Expected $ at start of path")
+	}
+	if strings.TrimRight(pathString, " \t\r\n") != pathString {
+		return nil, errors.New("Unexpected whitespace at end of path")
+	}
+	return genPath(pathString, dialectRFC9535, false)
+}
+
+func lexPathRFC9535(l lexer, state *intStack) stateFn {
+	cur := l.take()
+	switch cur {
+	case '$':
+		l.emit(pathRoot)
+	case '@':
+		l.emit(pathCurrent)
+	default:
+		return l.errorf("Expected $ or @ at start of path instead of %s", describeByte(cur))
+	}
+	return lexRFC9535Segment
+}
+
+func lexRFC9535Segment(l lexer, state *intStack) stateFn {
+	cur := l.take()
+	switch cur {
+	case '.':
+		if l.peek() == '.' {
+			l.take()
+			l.emit(pathDescendant)
+			if l.peek() == '[' {
+				return lexRFC9535Segment
+			}
+			return lexRFC9535MemberName
+		}
+		l.emit(pathPeriod)
+		return lexRFC9535MemberName
+	case '[':
+		l.emit(pathBracketLeft)
+		return lexRFC9535Selector
+	case eof:
+		l.emit(pathEOF)
+		return nil
+	}
+	return l.errorf("Unrecognized rune after path element %s", describeByte(cur))
+}
+
+func lexRFC9535MemberName(l lexer, state *intStack) stateFn {
+	if d, ok := state.peek(); ok && d == dialectJayway {
+		return lexJaywayMemberName
+	}
+	cur := l.peek()
+	if cur == '*' {
+		l.take()
+		l.emit(pathWildcard)
+		return lexRFC9535Segment
+	}
+	if !isNameFirst(cur) {
+		return l.errorf("Expected member name or * instead of %s", describeByte(cur))
+	}
+	for isNameFirst(cur) || (cur >= '0' && cur <= '9') {
+		l.take()
+		cur = l.peek()
+	}
+	l.emit(pathKey)
+	return lexRFC9535Segment
+}
+
+func isNameFirst(r int) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_' || r >= 0x80
+}
+
+func lexRFC9535Selector(l lexer, state *intStack) stateFn {
+	cur := l.peek()
+	switch {
+	case cur == '*':
+		l.take()
+		l.emit(pathWildcard)
+	case cur == '\'' || cur == '"':
+		if err := takeQuoted(l); err != nil {
+			return l.errorf("Could not take string because %q", err)
+		}
+		l.emit(pathKey)
+	case cur == '-' || (cur >= '0' && cur <= '9'):
+		if err := takeRFC9535Int(l); err != nil {
+			return l.errorf("%s", err)
+		}
+		l.emit(pathIndex)
+		return lexRFC9535Slice
+	case cur == ':':
+		return lexRFC9535Slice
+	case cur == '?':
+		l.take()
+		l.emit(pathWhere)
+		return lexRFC9535Filter
+	default:
+		return l.errorf("Unexpected %s in selector", describeByte(cur))
+	}
+	return lexRFC9535SelectorEnd
+}
+
+func lexRFC9535Filter(l lexer, state *intStack) stateFn {
+	if err := takeFilter(l); err != nil {
+		return l.errorf("%s", err)
+	}
+	l.emit(pathExpression)
+	return lexRFC9535SelectorEnd
+}
+
+func lexRFC9535Slice(l lexer, state *intStack) stateFn {
+	if l.peek() != ':' {
+		return lexRFC9535SelectorEnd
+	}
+	l.take()
+	l.emit(pathIndexRange)
+	return lexRFC9535SliceValue
+}
+
+func lexRFC9535SliceValue(l lexer, state *intStack) stateFn {
+	if cur := l.peek(); cur == '-' || (cur >= '0' && cur <= '9') {
+		if err := takeRFC9535Int(l); err != nil {
+			return l.errorf("%s", err)
+		}
+		l.emit(pathIndex)
+	}
+	return lexRFC9535Slice
+}
+
+func lexRFC9535SelectorEnd(l lexer, state *intStack) stateFn {
+	cur := l.take()
+	switch cur {
+	case ']':
+		l.emit(pathBracketRight)
+		return lexRFC9535Segment
+	case ',':
+		l.emit(pathComma)
+		return lexRFC9535Selector
+	}
+	return l.errorf("Expected ] or , after selector instead of %s", describeByte(cur))
+}
+
+// takeRFC9535Int takes an integer without leading zeros or negative zero
+// that fits the exact integer range of a double
+func takeRFC9535Int(l lexer) error {
+	negative := l.peek() == '-'
+	if negative {
+		l.take()
+	}
+	cur := l.take()
+	if cur < '0' || cur > '9' {
+		return fmt.Errorf("Expected digit instead of %s", describeByte(cur))
+	}
+	if cur == '0' {
+		if negative {
+			return errors.New("Negative zero is not a valid index")
+		}
+		if d := l.peek(); d >= '0' && d <= '9' {
+			return errors.New("Leading zeros are not allowed in indexes")
+		}
+		return nil
+	}
+
+	v := cur - '0'
+	for d := l.peek(); d >= '0' && d <= '9'; d = l.peek() {
+		l.take()
+		if v = v*10 + d - '0'; v > rfc9535MaxInt {
+			return errors.New("Index out of range")
+		}
+	}
+	return nil
+}
+
+// takeQuoted takes a string in single or double quotes. Escapes are only
+// skipped here and checked once the string is unquoted.
+func takeQuoted(l lexer) error {
+	quote := l.take()
+	for {
+		switch l.take() {
+		case quote:
+			return nil
+		case '\\':
+			l.take()
+		case eof:
+			return errors.New("End of file within string")
+		}
+	}
+}
+
+// takeFilter takes a filter expression up to the ] or , that ends its
+// selector
+func takeFilter(l lexer) error {
+	depth := 0
+	for {
+		cur := l.peek()
+		switch cur {
+		case eof:
+			return errors.New("Expected ] after filter expression")
+		case ']', ')':
+			if depth == 0 {
+				if cur == ')' {
+					return errors.New("Mismatched parentheses in filter expression")
+				}
+				return nil
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				return nil
+			}
+		case '[', '(':
+			depth++
+		case '\'', '"':
+			if err := takeQuoted(l); err != nil {
+				return err
+			}
+			continue
+		case '~':
+			if err := takeMatchOperand(l); err != nil {
+				return err
+			}
+			continue
+		}
+		l.take()
+	}
+}
+
+// takeMatchOperand takes the ~ of =~ and a regular expression after it, so
+// brackets and quotes in the expression are not mistaken for syntax
+func takeMatchOperand(l lexer) error {
+	l.take()
+	for l.peek() == ' ' || l.peek() == '\t' {
+		l.take()
+	}
+	if l.peek() != '/' {
+		return nil
+	}
+	return takeRegex(l)
+}
+
+// unquoteString decodes a string literal in single or double quotes using
+// the escapes of RFC 9535
+func unquoteString(val []byte) (string, error) {
+	if len(val) < 2 || (val[0] != '\'' && val[0] != '"') || val[len(val)-1] != val[0] {
+		return "", fmt.Errorf("Expected quoted string instead of %q", val)
+	}
+	quote := val[0]
+	val = val[1 : len(val)-1]
+
+	var sb strings.Builder
+	for x := 0; x < len(val); x++ {
+		c := val[x]
+		switch {
+		case c < 0x20:
+			return "", fmt.Errorf("Unescaped control character %#U in string", c)
+		case c == quote:
+			return "", fmt.Errorf("Unescaped %c in string", c)
+		case c != '\\':
+			sb.WriteByte(c)
+			continue
+		}
+
+		x++
+		if x == len(val) {
+			return "", errors.New("Unterminated escape in string")
+		}
+		switch val[x] {
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case '/', '\\':
+			sb.WriteByte(val[x])
+		case quote:
+			sb.WriteByte(quote)
+		case 'u':
+			r, n, err := unescapeRune(val[x+1:])
+			if err != nil {
+				return "", err
+			}
+			sb.WriteRune(r)
+			x += n
+		default:
+			return "", fmt.Errorf("Invalid escape \\%c in string", val[x])
+		}
+	}
+	return sb.String(), nil
+}
+
+// unescapeRune decodes the hex digits after \u, combining surrogate pairs,
+// and returns the number of bytes read
+func unescapeRune(val []byte) (rune, int, error) {
+	r, ok := hexRune(val)
+	if !ok {
+		return 0, 0, errors.New("Invalid \\u escape in string")
+	}
+	switch {
+	case r >= 0xDC00 && r <= 0xDFFF:
+		return 0, 0, errors.New("Unpaired low surrogate in string")
+	case r >= 0xD800 && r <= 0xDBFF:
+		if len(val) < 6 || val[4] != '\\' || val[5] != 'u' {
+			return 0, 0, errors.New("Unpaired high surrogate in string")
+		}
+		low, ok := hexRune(val[6:])
+		if !ok || low < 0xDC00 || low > 0xDFFF {
+			return 0, 0, errors.New("Unpaired high surrogate in string")
+		}
+		return (r-0xD800)<<10 + (low - 0xDC00) + 0x10000, 10, nil
+	}
+	return r, 4, nil
+}
+
+func hexRune(val []byte) (rune, bool) {
+	if len(val) < 4 {
+		return 0, false
+	}
+	var r rune
+	for _, c := range val[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			r = r<<4 + rune(c-'0')
+		case c >= 'a' && c <= 'f':
+			r = r<<4 + rune(c-'a'+10)
+		case c >= 'A' && c <= 'F':
+			r = r<<4 + rune(c-'A'+10)
+		default:
+			return 0, false
+		}
+	}
+	return r, true
+}
+
+// quoteString encodes s as a JSON string
+func quoteString(s string) []byte {
+	const hex = "0123456789abcdef"
+	b := make([]byte, 0, len(s)+2)
+	b = append(b, '"')
+	for x := 0; x < len(s); x++ {
+		c := s[x]
+		switch {
+		case c == '"' || c == '\\':
+			b = append(b, '\\', c)
+		case c == '\n':
+			b = append(b, '\\', 'n')
+		case c == '\r':
+			b = append(b, '\\', 'r')
+		case c == '\t':
+			b = append(b, '\\', 't')
+		case c < 0x20:
+			b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+		default:
+			b = append(b, c)
+		}
+	}
+	return append(b, '"')
+}
+
+// checkRFC9535Expression limits a lexed filter expression to what RFC 9535
+// allows. Negative numbers become single literals.
+func checkRFC9535Expression(items []Item, dialect int) ([]Item, error) {
+	checked := make([]Item, 0, len(items))
+	for x := 0; x < len(items); x++ {
+		item := items[x]
+		switch item.typ {
+		case exprOpMinusUn:
+			if x+1 == len(items) || items[x+1].typ != exprNumber {
+				return nil, errors.New("Expected number after -")
+			}
+			x++
+			item = Item{typ: exprNumber, pos: item.pos, val: append([]byte{'-'}, items[x].val...)}
+		case exprOpPlus, exprOpPlusUn, exprOpMinus, exprOpStar, exprOpSlash, exprOpPercent, exprOpHat:
+			return nil, fmt.Errorf("Operator %s is not supported in RFC 9535 or Jayway filters", exprTokenNames[item.typ])
+		case exprOpContains, exprOpStartsWith, exprOpEndsWith:
+			return nil, fmt.Errorf("Operator %s is not supported in RFC 9535 or Jayway filters", exprTokenNames[item.typ])
+		case exprOpMatch, exprOpIn, exprRegex, exprList:
+			if dialect != dialectJayway {
+				return nil, fmt.Errorf("%s is not supported in RFC 9535 filters", exprTokenNames[item.typ])
+			}
+		}
+		if item.typ == exprNumber && !validRFC9535Number(item.val) {
+			return nil, fmt.Errorf("Invalid number %q at %d", item.val, item.pos)
+		}
+		checked = append(checked, item)
+	}
+	return checked, nil
+}
+
+// validRFC9535Number reports whether val is a number literal without
+// leading zeros and with digits after any decimal point or exponent
+func validRFC9535Number(val []byte) bool {
+	x := 0
+	digits := func() bool {
+		start := x
+		for x < len(val) && val[x] >= '0' && val[x] <= '9' {
+			x++
+		}
+		return x > start
+	}
+
+	if x < len(val) && val[x] == '-' {
+		x++
+	}
+	if x < len(val) && val[x] == '0' {
+		x++
+	} else if !digits() {
+		return false
+	}
+	if x < len(val) && val[x] == '.' {
+		x++
+		if !digits() {
+			return false
+		}
+	}
+	if x < len(val) && (val[x] == 'e' || val[x] == 'E') {
+		x++
+		if x < len(val) && (val[x] == '-' || val[x] == '+') {
+			x++
+		}
+		if !digits() {
+			return false
+		}
+	}
+	return x == len(val)
+}
+
+// checkRFC9535Types applies the typing rules of RFC 9535: compared operands
+// are literals, singular queries or function values, and conditions are
+// comparisons, existence tests or logical functions
+func checkRFC9535Types(op *operator, dialect int) error {
+	const (
+		kindValue = iota
+		kindLogical
+		kindNodes
+	)
+	postFix := op.whereClause
+	kinds := make([]int, len(postFix))
+	names := make([]string, len(postFix))
+	for x, item := range postFix {
+		names[x] = "literal " + string(item.val)
+		switch item.typ {
+		case exprPathExists:
+			kinds[x] = kindLogical
+			names[x] = "query " + string(item.val)
+		case exprPathNodes:
+			kinds[x] = kindNodes
+			names[x] = "query " + string(item.val)
+		case exprPath, exprPathValue:
+			p, err := genPath(string(item.val), dialect, false)
+			if err != nil {
+				return err
+			}
+			names[x] = "query " + string(item.val)
+			if !p.singular() {
+				kinds[x] = kindNodes
+				names[x] = "non-singular query " + string(item.val)
+			}
+		}
+	}
+
+	want := func(x, o, kind int) error {
+		if kinds[o] == kind {
+			return nil
+		}
+		var err error
+		switch {
+		case postFix[x].typ == exprFunc:
+			err = fmt.Errorf("%s is not a valid argument of %s)", names[o], postFix[x].val)
+		case kind == kindValue:
+			err = fmt.Errorf("%s cannot be compared", names[o])
+		default:
+			err = fmt.Errorf("%s must be compared", names[o])
+		}
+		return filterError(op, postFix[x], dialect, err)
+	}
+
+	root, err := walkPostFix(postFix, func(x int, operands []int) error {
+		item := postFix[x]
+		switch item.typ {
+		case exprFunc:
+			f, err := op.function(item.val)
+			if err != nil {
+				return err
+			}
+			for k, a := range operands {
+				kind := kindValue
+				switch f.signature.Params[k] {
+				case LogicalType:
+					kind = kindLogical
+				case NodesType:
+					kind = kindNodes
+				}
+				if err := want(x, a, kind); err != nil {
+					return err
+				}
+			}
+			kinds[x] = kindValue
+			if f.signature.Result == LogicalType {
+				kinds[x] = kindLogical
+			}
+			names[x] = fmt.Sprintf("result of %s)", item.val)
+			return nil
+		case exprOpAnd, exprOpOr, exprOpNot:
+			for _, o := range operands {
+				if err := want(x, o, kindLogical); err != nil {
+					return err
+				}
+			}
+		default:
+			for _, o := range operands {
+				if err := want(x, o, kindValue); err != nil {
+					return err
+				}
+			}
+		}
+		kinds[x] = kindLogical
+		names[x] = "result of " + exprTokenNames[item.typ]
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if kinds[root] != kindLogical {
+		return filterError(op, postFix[root], dialect, fmt.Errorf("%s must be compared", names[root]))
+	}
+	return nil
+}
+
+// markExistenceTests turns the paths of a postfix expression that are not
+// compared but used as conditions into existence tests
+func markExistenceTests(postFix []Item) {
+	root, err := walkPostFix(postFix, func(x int, operands []int) error {
+		switch postFix[x].typ {
+		case exprOpAnd, exprOpOr, exprOpNot:
+			for _, o := range operands {
+				if postFix[o].typ == exprPath {
+					postFix[o].typ = exprPathExists
+				}
+			}
+		}
+		return nil
+	})
+	if err == nil && postFix[root].typ == exprPath {
+		postFix[root].typ = exprPathExists
+	}
+}
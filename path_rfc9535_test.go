@@ -0,0 +1,151 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// knownFailures are the compliance tests that fail by design: values are
+// returned once and in document order, while the RFC repeats values selected
+// twice and orders them by selector, and descendants by depth
+var knownFailures = map[string]struct{}{
+	"basic, multiple selectors, index and slice, overlapping":     struct{}{},
+	"basic, multiple selectors, duplicate index":                  struct{}{},
+	"basic, multiple selectors, wildcard and index":               struct{}{},
+	"basic, multiple selectors, wildcard and name":                struct{}{},
+	"basic, multiple selectors, wildcard and slice":               struct{}{},
+	"basic, multiple selectors, multiple wildcards":               struct{}{},
+	"basic, descendant segment, wildcard selector, nested arrays": struct{}{},
+	"filter, multiple selectors, overlapping":                     struct{}{},
+	"filter, multiple selectors, filter and wildcard":             struct{}{},
+}
+
+var rfc9535OpTests = []optest{
+	optest{"name shorthand", `$.aKey`, []int{opTypeName}},
+	optest{"single quoted name", `$['aKey']`, []int{opTypeName}},
+	optest{"wildcard shorthand", `$.*`, []int{opTypeUnion}},
+	optest{"wildcard selector", `$[*]`, []int{opTypeUnion}},
+	optest{"filter", `$.items[?@.price < 10]`, []int{opTypeName, opTypeUnion}},
+	optest{"filter and index", `$[?@.a,1]`, []int{opTypeUnion}},
+	optest{"whitespace between segments", `$ .a ['b']`, []int{opTypeName, opTypeName}},
+}
+
+func TestRFC9535Operators(t *testing.T) {
+	as := assert.New(t)
+
+	for _, t := range rfc9535OpTests {
+		paths, err := ParsePathsRFC9535(t.path)
+		if !as.NoError(err, t.name) {
+			continue
+		}
+
+		as.EqualValues(len(t.expected), len(paths[0].operators))
+		for x, op := range t.expected {
+			as.EqualValues(op, paths[0].operators[x].typ, t.name)
+		}
+	}
+
+	paths, err := ParsePathsRFC9535(`$['a\'b',"\u263a"]`)
+	if as.NoError(err) {
+		as.EqualValues(map[string]struct{}{`a'b`: struct{}{}, "\u263a": struct{}{}}, paths[0].operators[0].keyStrings)
+	}
+
+	for _, p := range []string{`$.a+`, `@.a`, ` $.a`, `$.a `, `$. a`, `$[01]`, `$[-0]`, `$["\q"]`, `$[?@.a + 1 == 2]`, `$[?@.a == 1.]`, `$[?@.a =~ /x/]`, `$[?@.a in [1]]`, `$[?@.a contains 'x']`, `$[?true]`, `$[?@.* == 1]`, `$[?length(@.a)]`, `$[?match(@.a, 'x') == true]`, `$[?length(@..a) == 1]`} {
+		_, err := ParsePathsRFC9535(p)
+		as.Error(err, p)
+	}
+
+	for p, msg := range map[string]string{
+		`$..`: `Expected member name or * instead of EOF`,
+		`$[`:  `Unexpected value within brackets: "Unexpected EOF in selector"`,
+		`$[1`: `Unexpected value within brackets after index: "Expected ] or , after selector instead of EOF"`,
+		`$[-`: `Unexpected value within brackets: "Expected digit instead of EOF"`,
+	} {
+		_, err := ParsePathsRFC9535(p)
+		as.EqualError(err, msg, p)
+	}
+}
+
+type complianceTest struct {
+	Name            string          `json:"name"`
+	Selector        string          `json:"selector"`
+	Document        json.RawMessage `json:"document"`
+	Result          []interface{}   `json:"result"`
+	Results         [][]interface{} `json:"results"`
+	InvalidSelector bool            `json:"invalid_selector"`
+}
+
+func TestRFC9535Compliance(t *testing.T) {
+	as := assert.New(t)
+
+	data, err := os.ReadFile("testdata/cts.json")
+	if !as.NoError(err) {
+		return
+	}
+	var suite struct {
+		Tests []complianceTest `json:"tests"`
+	}
+	if !as.NoError(json.Unmarshal(data, &suite)) {
+		return
+	}
+
+	passed := 0
+	for _, test := range suite.Tests {
+		err := runComplianceTest(test)
+		if _, known := knownFailures[test.Name]; known {
+			as.NotEqual("", err, "%s passes, remove it from knownFailures", test.Name)
+		} else {
+			as.Equal("", err, test.Name)
+		}
+		if err == "" {
+			passed++
+		}
+	}
+	t.Logf("RFC 9535 compliance: %d of the %d tests in testdata/cts.json passed", passed, len(suite.Tests))
+}
+
+func runComplianceTest(test complianceTest) string {
+	paths, err := ParsePathsRFC9535(test.Selector)
+	if test.InvalidSelector {
+		if err == nil {
+			return "expected the selector to be rejected"
+		}
+		return ""
+	}
+	if err != nil {
+		return "could not parse: " + err.Error()
+	}
+
+	eval, err := EvalPathsInBytes(test.Document, paths)
+	if err != nil {
+		return err.Error()
+	}
+	results, err := drainResults(eval)
+	if err != nil {
+		return err.Error()
+	}
+	actual := make([]interface{}, 0, len(results))
+	for _, r := range results {
+		var v interface{}
+		if err := json.Unmarshal(r.Value, &v); err != nil {
+			return "bad result value: " + string(r.Value)
+		}
+		actual = append(actual, v)
+	}
+
+	expected := test.Results
+	if expected == nil {
+		expected = [][]interface{}{test.Result}
+	}
+	for _, e := range expected {
+		if len(e) == len(actual) && (len(e) == 0 || reflect.DeepEqual(e, actual)) {
+			return ""
+		}
+	}
+	got, _ := json.Marshal(actual)
+	return "unexpected result " + string(got)
+}
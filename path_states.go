@@ -1,5 +1,10 @@
 package jsonpath
 
+import (
+	"errors"
+	"fmt"
+)
+
 const (
 	pathError = iota
 	pathEOF
@@ -11,10 +16,12 @@ const (
 	pathBracketRight
 	pathIndex
 	pathOr
+	pathComma
 	pathIndexRange
 	pathLength
 	pathWildcard
 	pathPeriod
+	pathDescendant
 	pathValue
 	pathWhere
 	pathExpression
@@ -31,10 +38,12 @@ var pathTokenNames = map[int]string{
 	pathBracketRight: "]",
 	pathIndex:        "INDEX",
 	pathOr:           "|",
+	pathComma:        ",",
 	pathIndexRange:   ":",
 	pathLength:       "LENGTH",
 	pathWildcard:     "*",
 	pathPeriod:       ".",
+	pathDescendant:   "..",
 	pathValue:        "+",
 	pathWhere:        "?",
 	pathExpression:   "EXPRESSION",
@@ -61,6 +70,14 @@ func lexPathAfterKey(l lexer, state *intStack) stateFn {
 	cur := l.take()
 	switch cur {
 	case '.':
+		if l.peek() == '.' {
+			l.take()
+			l.emit(pathDescendant)
+			if l.peek() == '[' {
+				return lexPathAfterKey
+			}
+			return lexKey
+		}
 		l.emit(pathPeriod)
 		return lexKey
 	case '[':
@@ -81,13 +98,44 @@ func lexPathAfterKey(l lexer, state *intStack) stateFn {
 }
 
 func lexPathExpression(l lexer, state *intStack) stateFn {
+	if err := takeExpression(l); err != nil {
+		return l.errorf("%s", err)
+	}
+	l.emit(pathExpression)
+	return lexPathAfterKey
+}
+
+// lexPathBracketExpression lexes the expression of a filter inside brackets,
+// like [?(@.price > 10)]
+func lexPathBracketExpression(l lexer, state *intStack) stateFn {
+	if err := takeExpression(l); err != nil {
+		return l.errorf("%s", err)
+	}
+	l.emit(pathExpression)
+	return lexPathBracketClose
+}
+
+// takeExpression takes a filter expression in parentheses
+func takeExpression(l lexer) error {
 	cur := l.take()
 	if cur != '(' {
-		return l.errorf("Expected $ at start of path instead of  %#U", cur)
+		return fmt.Errorf("Expected ( at start of expression instead of  %#U", cur)
 	}
 
 	parenLeftCount := 1
 	for {
+		switch l.peek() {
+		case '"', '\'':
+			if err := takeQuoted(l); err != nil {
+				return err
+			}
+			continue
+		case '~':
+			if err := takeMatchOperand(l); err != nil {
+				return err
+			}
+			continue
+		}
 		cur = l.take()
 		switch cur {
 		case '(':
@@ -95,15 +143,13 @@ func lexPathExpression(l lexer, state *intStack) stateFn {
 		case ')':
 			parenLeftCount--
 		case eof:
-			return l.errorf("Unexpected EOF within expression")
+			return errors.New("Unexpected EOF within expression")
 		}
 
 		if parenLeftCount == 0 {
-			break
+			return nil
 		}
 	}
-	l.emit(pathExpression)
-	return lexPathAfterKey
 }
 
 func lexPathBracketOpen(l lexer, state *intStack) stateFn {
@@ -116,11 +162,18 @@ func lexPathBracketOpen(l lexer, state *intStack) stateFn {
 		l.takeString()
 		l.emit(pathKey)
 		return lexPathBracketClose
-	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-		l.take()
-		takeDigits(l)
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		if err := takePathIndex(l); err != nil {
+			return l.errorf("%s", err)
+		}
 		l.emit(pathIndex)
 		return lexPathIndexRange
+	case ':':
+		return lexPathIndexRange
+	case '?':
+		l.take()
+		l.emit(pathWhere)
+		return lexPathBracketExpression
 	case eof:
 		l.emit(pathEOF)
 	}
@@ -129,11 +182,15 @@ func lexPathBracketOpen(l lexer, state *intStack) stateFn {
 
 func lexPathBracketClose(l lexer, state *intStack) stateFn {
 	cur := l.take()
-	if cur != ']' {
-		return l.errorf("Expected ] instead of  %#U", cur)
+	switch cur {
+	case ']':
+		l.emit(pathBracketRight)
+		return lexPathAfterKey
+	case ',':
+		l.emit(pathComma)
+		return lexPathBracketOpen
 	}
-	l.emit(pathBracketRight)
-	return lexPathAfterKey
+	return l.errorf("Expected ] or , instead of  %#U", cur)
 }
 
 func lexKey(l lexer, state *intStack) stateFn {
@@ -173,7 +230,7 @@ func lexPathIndexRange(l lexer, state *intStack) stateFn {
 		l.take()
 		l.emit(pathIndexRange)
 		return lexPathIndexRangeSecond
-	case ']':
+	case ']', ',':
 		return lexPathBracketClose
 	default:
 		return l.errorf("Expected digit or ] instead of  %#U", cur)
@@ -183,17 +240,60 @@ func lexPathIndexRange(l lexer, state *intStack) stateFn {
 func lexPathIndexRangeSecond(l lexer, state *intStack) stateFn {
 	cur := l.peek()
 	switch cur {
-	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-		takeDigits(l)
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		if err := takePathIndex(l); err != nil {
+			return l.errorf("%s", err)
+		}
 		l.emit(pathIndex)
+		return lexPathIndexStep
+	case ':', ']', ',':
+		return lexPathIndexStep
+	default:
+		return l.errorf("Expected digit or ] instead of  %#U", cur)
+	}
+}
+
+func lexPathIndexStep(l lexer, state *intStack) stateFn {
+	cur := l.peek()
+	switch cur {
+	case ':':
+		l.take()
+		l.emit(pathIndexRange)
+		return lexPathIndexStepValue
+	case ']', ',':
 		return lexPathBracketClose
-	case ']':
+	default:
+		return l.errorf("Expected : or ] instead of  %#U", cur)
+	}
+}
+
+func lexPathIndexStepValue(l lexer, state *intStack) stateFn {
+	cur := l.peek()
+	switch cur {
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		if err := takePathIndex(l); err != nil {
+			return l.errorf("%s", err)
+		}
+		l.emit(pathIndex)
+		return lexPathBracketClose
+	case ']', ',':
 		return lexPathBracketClose
 	default:
 		return l.errorf("Expected digit or ] instead of  %#U", cur)
 	}
 }
 
+func takePathIndex(l lexer) error {
+	if l.peek() == '-' {
+		l.take()
+	}
+	if d := l.peek(); !(d >= '0' && d <= '9') {
+		return fmt.Errorf("Expected digit in index instead of %#U", d)
+	}
+	takeDigits(l)
+	return nil
+}
+
 func lexPathArrayClose(l lexer, state *intStack) stateFn {
 	cur := l.take()
 	if cur != ']' {
@@ -16,7 +16,16 @@ var pathTests = []lexTest{
 	{"wildcard key", `$.akey.*.akey3`, []int{pathRoot, pathPeriod, pathKey, pathPeriod, pathWildcard, pathPeriod, pathKey, pathEOF}},
 	{"wildcard index", `$.akey[*]`, []int{pathRoot, pathPeriod, pathKey, pathBracketLeft, pathWildcard, pathBracketRight, pathEOF}},
 	{"key with where expression", `$.akey?(@.ten = 5)`, []int{pathRoot, pathPeriod, pathKey, pathWhere, pathExpression, pathEOF}},
+	{"bracket filter", `$.akey[?(@[0] > 5)].b`, []int{pathRoot, pathPeriod, pathKey, pathBracketLeft, pathWhere, pathExpression, pathBracketRight, pathPeriod, pathKey, pathEOF}},
+	{"bracket filter without parentheses", `$.akey[?@ > 5]`, []int{pathRoot, pathPeriod, pathKey, pathBracketLeft, pathWhere, pathError}},
+	{"recursive descent", `$..akey`, []int{pathRoot, pathDescendant, pathKey, pathEOF}},
+	{"recursive descent wildcard", `$.akey..*`, []int{pathRoot, pathPeriod, pathKey, pathDescendant, pathWildcard, pathEOF}},
+	{"recursive descent bracket", `$..[*]`, []int{pathRoot, pathDescendant, pathBracketLeft, pathWildcard, pathBracketRight, pathEOF}},
 	{"bracket notation", `$["aKey"][*][32][23:42]`, []int{pathRoot, pathBracketLeft, pathKey, pathBracketRight, pathBracketLeft, pathWildcard, pathBracketRight, pathBracketLeft, pathIndex, pathBracketRight, pathBracketLeft, pathIndex, pathIndexRange, pathIndex, pathBracketRight, pathEOF}},
+	{"negative indexes", `$[-1][-3:][2:-4]`, []int{pathRoot, pathBracketLeft, pathIndex, pathBracketRight, pathBracketLeft, pathIndex, pathIndexRange, pathBracketRight, pathBracketLeft, pathIndex, pathIndexRange, pathIndex, pathBracketRight, pathEOF}},
+	{"slice with step", `$[1:5:2][::-1][:3]`, []int{pathRoot, pathBracketLeft, pathIndex, pathIndexRange, pathIndex, pathIndexRange, pathIndex, pathBracketRight, pathBracketLeft, pathIndexRange, pathIndexRange, pathIndex, pathBracketRight, pathBracketLeft, pathIndexRange, pathIndex, pathBracketRight, pathEOF}},
+	{"union", `$["a","b"][0, 1:2,*]`, []int{pathRoot, pathBracketLeft, pathKey, pathComma, pathKey, pathBracketRight, pathBracketLeft, pathIndex, pathComma, pathIndex, pathIndexRange, pathIndex, pathComma, pathWildcard, pathBracketRight, pathEOF}},
+	{"dash without digits", `$[-]`, []int{pathRoot, pathBracketLeft, pathError}},
 }
 
 func TestValidPaths(t *testing.T) {
@@ -19,9 +19,17 @@ var optests = []optest{
 	optest{"single index", `$[12]`, []int{opTypeIndex}},
 	optest{"single key", `$[23:45]`, []int{opTypeIndexRange}},
 	optest{"single key", `$[*]`, []int{opTypeIndexWild}},
+	optest{"slice with step", `$[1:9:2]`, []int{opTypeIndexRange}},
+	optest{"slice with defaults", `$[::]`, []int{opTypeIndexRange}},
+	optest{"key union", `$["aKey","bKey"]`, []int{opTypeNameList}},
+	optest{"index union", `$[0,3,5]`, []int{opTypeUnion}},
+	optest{"mixed union", `$[0,"name",2:4]`, []int{opTypeUnion}},
+	optest{"bracket filter", `$[?(@ > 1)]`, []int{opTypeUnion}},
 
 	optest{"double key", `$["aKey"]["bKey"]`, []int{opTypeName, opTypeName}},
 	optest{"double key", `$["aKey"].bKey`, []int{opTypeName, opTypeName}},
+	optest{"recursive descent", `$..aKey`, []int{opTypeName}},
+	optest{"recursive descent", `$.aKey..[*]`, []int{opTypeName, opTypeIndexWild}},
 }
 
 func TestQueryOperators(t *testing.T) {
@@ -38,3 +46,93 @@ func TestQueryOperators(t *testing.T) {
 		}
 	}
 }
+
+func TestDescendantOperators(t *testing.T) {
+	as := assert.New(t)
+
+	path, err := parsePath(`$.a..b.c`)
+	if as.NoError(err) {
+		as.False(path.operators[0].descendant)
+		as.True(path.operators[1].descendant)
+		as.False(path.operators[2].descendant)
+		as.EqualValues(2, len(path.operators[1].descendantPath.operators))
+	}
+
+	for _, p := range []string{`$..`, `$..+`, `$....a`} {
+		_, err := parsePath(p)
+		as.Error(err, "Testing: %s", p)
+	}
+}
+
+func TestWhereClauseLiterals(t *testing.T) {
+	as := assert.New(t)
+
+	path, err := parsePath(`$.a[*]?(@.b =~ /x(y)?/i && @.c in ['d)', 2]).e+`)
+	if as.NoError(err) {
+		op := path.operators[1]
+		if as.Contains(op.regexps, `/x(y)?/i`) {
+			as.True(op.regexps[`/x(y)?/i`].MatchString("XY"))
+		}
+		as.Equal(map[string][]interface{}{`['d)', 2]`: {[]byte(`"d)"`), 2.0}}, op.lists)
+	}
+
+	for _, p := range []string{
+		`$.a[*]?(@.b =~ /x/g)`,
+		`$.a[*]?(@.b =~ /(/)`,
+		`$.a[*]?(@.b like "x")`,
+		`$.a[*]?(@.b in [[1]])`,
+		`$.a[*]?(@.b in ['x)`,
+		`$.a[?(@.b),-1]`,
+	} {
+		_, err := parsePath(p)
+		as.Error(err, "Testing: %s", p)
+	}
+}
+
+func TestWindowedIndexSelection(t *testing.T) {
+	as := assert.New(t)
+
+	tests := []struct {
+		path     string
+		length   int
+		expected []int
+	}{
+		{`$[-1]`, 4, []int{3}},
+		{`$[-4]`, 4, []int{0}},
+		{`$[-5]`, 4, []int{}},
+		{`$[-2:]`, 4, []int{2, 3}},
+		{`$[-9:]`, 4, []int{0, 1, 2, 3}},
+		{`$[1:-1]`, 4, []int{1, 2}},
+		{`$[-3:-1]`, 4, []int{1, 2}},
+		{`$[-1:-3]`, 4, []int{}},
+		{`$[-3:2]`, 4, []int{1}},
+		{`$[::-1]`, 4, []int{0, 1, 2, 3}},
+		{`$[-1:0:-2]`, 4, []int{1, 3}},
+		{`$[10:-10:-1]`, 3, []int{0, 1, 2}},
+		{`$[-3::2]`, 5, []int{2, 4}},
+		{`$[1:-1:2]`, 6, []int{1, 3}},
+		{`$[-2:3:0]`, 5, []int{}},
+	}
+
+	for _, test := range tests {
+		path, err := parsePath(test.path)
+		if !as.NoError(err) {
+			continue
+		}
+		op := path.operators[0]
+		as.True(op.windowed(), "Testing: %s", test.path)
+
+		selected := []int{}
+		for i := 0; i < test.length; i++ {
+			if ok, _ := op.selectIndex(i, test.length, true); ok {
+				selected = append(selected, i)
+			}
+			// An early decision must agree with the final one
+			if ok, known := op.selectIndex(i, i+1, false); known {
+				final, _ := op.selectIndex(i, test.length, true)
+				as.EqualValues(final, ok, "Testing: %s at %d", test.path, i)
+			}
+		}
+		as.EqualValues(test.expected, selected, "Testing: %s", test.path)
+	}
+}
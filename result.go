@@ -2,11 +2,21 @@ package jsonpath
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"strconv"
 )
 
+// Kind is the JSON type of a result value
+type Kind int
+
 const (
-	JsonObject = iota
+	// Unknown is the kind of results without a value
+	Unknown Kind = iota
+	JsonObject
 	JsonArray
 	JsonString
 	JsonNumber
@@ -14,10 +24,146 @@ const (
 	JsonBool
 )
 
+var kindNames = map[Kind]string{
+	Unknown:    "unknown",
+	JsonObject: "object",
+	JsonArray:  "array",
+	JsonString: "string",
+	JsonNumber: "number",
+	JsonNull:   "null",
+	JsonBool:   "bool",
+}
+
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// ErrNoValue is returned when decoding a result whose path does not end in +
+var ErrNoValue = errors.New("Result has no value")
+
+// TypeError is returned when a result is decoded as a type it does not hold
+type TypeError struct {
+	Expected Kind
+	Actual   Kind
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("Result is a JSON %s, not a %s", e.Actual, e.Expected)
+}
+
 type Result struct {
 	Keys  []interface{}
 	Value []byte
-	Type  int
+	Type  Kind
+
+	// Position of the value in the input. Length covers the bytes of the
+	// value as written, including any whitespace inside it. Line and Column
+	// are 1-based and count bytes.
+	Offset int64
+	Length int
+	Line   int
+	Column int
+
+	// Document is the index of the document the value is in, for inputs of
+	// several documents
+	Document int
+
+	held *heldFilter // placeholder for a filter waiting on a $ path
+}
+
+func (r *Result) check(expected Kind) error {
+	if r.Value == nil {
+		return ErrNoValue
+	}
+	if r.Type != expected {
+		return &TypeError{Expected: expected, Actual: r.Type}
+	}
+	return nil
+}
+
+// Decode unmarshals the value into v like json.Unmarshal
+func (r *Result) Decode(v interface{}) error {
+	if r.Value == nil {
+		return ErrNoValue
+	}
+	return json.Unmarshal(r.Value, v)
+}
+
+// String returns the unescaped contents of a string value
+func (r *Result) String() (string, error) {
+	if err := r.check(JsonString); err != nil {
+		return "", err
+	}
+	if bytes.IndexByte(r.Value, '\\') < 0 {
+		return string(r.Value[1 : len(r.Value)-1]), nil
+	}
+	var s string
+	err := json.Unmarshal(r.Value, &s)
+	return s, err
+}
+
+// Int64 returns a number value that is an integer, also when it is written
+// with a fraction or exponent such as 1.0 or 1e3
+func (r *Result) Int64() (int64, error) {
+	if err := r.check(JsonNumber); err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(string(r.Value), 10, 64)
+	if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrSyntax {
+		f, fErr := strconv.ParseFloat(string(r.Value), 64)
+		if fErr == nil && f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			return int64(f), nil
+		}
+	}
+	return i, err
+}
+
+// Number returns a number value as written, without losing precision
+func (r *Result) Number() (json.Number, error) {
+	if err := r.check(JsonNumber); err != nil {
+		return "", err
+	}
+	return json.Number(r.Value), nil
+}
+
+// BigInt returns a number value that is an integer without losing precision,
+// also when it is written with a fraction or exponent such as 1.0 or 1e30
+func (r *Result) BigInt() (*big.Int, error) {
+	if err := r.check(JsonNumber); err != nil {
+		return nil, err
+	}
+	if i, ok := new(big.Int).SetString(string(r.Value), 10); ok {
+		return i, nil
+	}
+	rat, ok := new(big.Rat).SetString(string(r.Value))
+	if !ok || !rat.IsInt() {
+		return nil, fmt.Errorf("Number %s is not an integer", r.Value)
+	}
+	return rat.Num(), nil
+}
+
+// Float64 returns a number value
+func (r *Result) Float64() (float64, error) {
+	if err := r.check(JsonNumber); err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(string(r.Value), 64)
+}
+
+// Bool returns a true or false value
+func (r *Result) Bool() (bool, error) {
+	if err := r.check(JsonBool); err != nil {
+		return false, err
+	}
+	return r.Value[0] == 't', nil
+}
+
+// IsNull reports whether the value is null
+func (r *Result) IsNull() bool {
+	return r.Value != nil && r.Type == JsonNull
 }
 
 func (r *Result) Pretty(showPath bool) string {
@@ -0,0 +1,93 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func result(value string, typ Kind) *Result {
+	r := newResult(value, typ)
+	return &r
+}
+
+func TestResultAccessors(t *testing.T) {
+	as := assert.New(t)
+
+	s, err := result(`"café \"x\""`, JsonString).String()
+	as.NoError(err)
+	as.Equal(`café "x"`, s)
+
+	s, err = result(`"plain"`, JsonString).String()
+	as.NoError(err)
+	as.Equal(`plain`, s)
+
+	for value, expected := range map[string]int64{`42`: 42, `-7`: -7, `1.0`: 1, `1e3`: 1000} {
+		i, err := result(value, JsonNumber).Int64()
+		as.NoError(err, value)
+		as.Equal(expected, i, value)
+	}
+	_, err = result(`1.5`, JsonNumber).Int64()
+	as.IsType(&strconv.NumError{}, err)
+
+	n, err := result(`9007199254740993`, JsonNumber).Number()
+	as.NoError(err)
+	as.Equal(json.Number(`9007199254740993`), n)
+
+	for value, expected := range map[string]string{`9007199254740993`: "9007199254740993", `-1e30`: "-1000000000000000000000000000000", `12.0`: "12"} {
+		i, err := result(value, JsonNumber).BigInt()
+		as.NoError(err, value)
+		as.Equal(expected, i.String(), value)
+	}
+	_, err = result(`1.5`, JsonNumber).BigInt()
+	as.EqualError(err, "Number 1.5 is not an integer")
+
+	f, err := result(`2.5e-1`, JsonNumber).Float64()
+	as.NoError(err)
+	as.Equal(0.25, f)
+
+	b, err := result(`false`, JsonBool).Bool()
+	as.NoError(err)
+	as.False(b)
+
+	as.True(result(`null`, JsonNull).IsNull())
+	as.False(result(`0`, JsonNumber).IsNull())
+	as.False((&Result{}).IsNull())
+
+	var v struct{ A []int }
+	as.NoError(result(`{"A":[1,2]}`, JsonObject).Decode(&v))
+	as.Equal([]int{1, 2}, v.A)
+}
+
+func TestResultTypeErrors(t *testing.T) {
+	as := assert.New(t)
+
+	_, err := result(`"42"`, JsonString).Int64()
+	as.Equal(&TypeError{Expected: JsonNumber, Actual: JsonString}, err)
+	as.EqualError(err, "Result is a JSON string, not a number")
+
+	_, err = result(`true`, JsonBool).BigInt()
+	as.Equal(&TypeError{Expected: JsonNumber, Actual: JsonBool}, err)
+
+	_, err = result(`1`, JsonNumber).Bool()
+	as.Equal(&TypeError{Expected: JsonBool, Actual: JsonNumber}, err)
+
+	_, err = result(`[]`, JsonArray).String()
+	as.Equal(&TypeError{Expected: JsonString, Actual: JsonArray}, err)
+
+	_, err = (&Result{Keys: []interface{}{"a"}}).Float64()
+	as.Equal(ErrNoValue, err)
+	as.Equal(ErrNoValue, (&Result{}).Decode(&struct{}{}))
+}
+
+func TestKindString(t *testing.T) {
+	as := assert.New(t)
+
+	as.Equal("string", JsonString.String())
+	as.Equal("bool", JsonBool.String())
+	as.Equal("unknown", Unknown.String())
+	as.Equal("Kind(42)", Kind(42).String())
+	as.Equal(Unknown, (&Result{Keys: []interface{}{"a"}}).Type)
+}
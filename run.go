@@ -1,19 +1,113 @@
 package jsonpath
 
-import "io"
+import (
+	"context"
+	"io"
+)
 
-func EvalPathsInBytes(input []byte, paths []*Path) (*Eval, error) {
+// Options change how the input of an evaluation is read
+type Options struct {
+	// Strict rejects input that is not valid RFC 8259 JSON, such as control
+	// characters, invalid escapes or invalid UTF-8 in strings and numbers
+	// with leading zeros. The whole input is read, so errors after the last
+	// result are reported too.
+	Strict bool
+
+	// The lenient options accept JSONC and JSON5 style input. Results hold
+	// the values rewritten as standard JSON, while their offsets and lengths
+	// refer to the input as written.
+
+	// Comments allows // and /* */ comments wherever whitespace is allowed
+	Comments bool
+	// TrailingCommas allows a comma after the last member of an object or
+	// the last element of an array
+	TrailingCommas bool
+	// SingleQuotes allows strings and keys in single quotes
+	SingleQuotes bool
+	// UnquotedKeys allows keys that are identifiers, such as {name: "x"}
+	UnquotedKeys bool
+	// HexNumbers allows hexadecimal integers, such as 0x1F
+	HexNumbers bool
+}
+
+// lenient reports whether any of the lenient options is set
+func (o Options) lenient() bool {
+	return o.Comments || o.TrailingCommas || o.SingleQuotes || o.UnquotedKeys || o.HexNumbers
+}
+
+func EvalPathsInBytes(input []byte, paths []*Path, opts ...Options) (*Eval, error) {
 	lexer := NewSliceLexer(input, JSON)
-	eval := newEvaluation(lexer, paths...)
+	eval := newEvaluationOptions(lexer, paths, opts)
 	return eval, nil
 }
 
-func EvalPathsInReader(r io.Reader, paths []*Path) (*Eval, error) {
+func EvalPathsInReader(r io.Reader, paths []*Path, opts ...Options) (*Eval, error) {
 	lexer := NewReaderLexer(r, JSON)
-	eval := newEvaluation(lexer, paths...)
+	eval := newEvaluationOptions(lexer, paths, opts)
+	return eval, nil
+}
+
+// EvalPathsInReaderContext evaluates the paths like EvalPathsInReader, but
+// stops between tokens once ctx is done and sets Eval.Error to ctx.Err().
+// A read that is blocked in r is not interrupted, so r should be closed when
+// ctx is done if it may block for long.
+func EvalPathsInReaderContext(ctx context.Context, r io.Reader, paths []*Path, opts ...Options) (*Eval, error) {
+	eval, err := EvalPathsInReader(r, paths, opts...)
+	if err != nil {
+		return nil, err
+	}
+	eval.ctx = ctx
+	eval.done = ctx.Done()
+	return eval, nil
+}
+
+// EvalPathsInJSONLines evaluates the paths on each document of r, which are
+// separated by whitespace such as one per line in newline-delimited JSON
+func EvalPathsInJSONLines(r io.Reader, paths []*Path, opts ...Options) (*Eval, error) {
+	lexer := NewReaderLexer(r, lexJsonLines)
+	eval := newEvaluationOptions(lexer, paths, opts)
+	eval.documents = true
 	return eval, nil
 }
 
+// EvalPathsInJSONSeq evaluates the paths on each document of an RFC 7464
+// JSON text sequence, where every document starts with a record separator
+func EvalPathsInJSONSeq(r io.Reader, paths []*Path, opts ...Options) (*Eval, error) {
+	lexer := NewReaderLexer(r, lexJsonSeq)
+	eval := newEvaluationOptions(lexer, paths, opts)
+	eval.documents = true
+	return eval, nil
+}
+
+// Validate reads a JSON document from r and returns the first way in which
+// it is not valid RFC 8259 JSON, or nil
+func Validate(r io.Reader) error {
+	eval, _ := EvalPathsInReader(r, nil, Options{Strict: true})
+	for {
+		if _, ok := eval.Iterate(); !ok {
+			break
+		}
+	}
+	return eval.Error
+}
+
+type optionsLexer interface {
+	tokenReader
+	setOptions(Options)
+}
+
+// newEvaluationOptions starts an evaluation with the last of opts, if any
+func newEvaluationOptions(l optionsLexer, paths []*Path, opts []Options) *Eval {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[len(opts)-1]
+	}
+	l.setOptions(o)
+	eval := newEvaluation(l, paths...)
+	eval.readAll = o.Strict
+	return eval
+}
+
 func ParsePaths(pathStrings ...string) ([]*Path, error) {
 	paths := make([]*Path, len(pathStrings))
 	for x, p := range pathStrings {
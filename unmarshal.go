@@ -0,0 +1,197 @@
+package jsonpath
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Unmarshal fills the fields of the struct pointed to by v that carry a
+// jsonpath tag, reading data in a single pass:
+//
+//	type Order struct {
+//		ID    string   `jsonpath:"$.id"`
+//		Tags  []string `jsonpath:"$.tags[*]"`
+//		Items []struct {
+//			SKU string `jsonpath:"@.sku"`
+//		} `jsonpath:"$.items[*]"`
+//	}
+//
+// Paths need no trailing +. A slice field receives every match, any other
+// field the first one. Tags of a nested struct starting with @ are relative
+// to the path of the struct field, which fills one struct, or one slice
+// element, per match.
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("Unmarshal expects a non-nil pointer to a struct")
+	}
+
+	plan, err := planStruct(rv.Elem().Type(), "")
+	if err != nil {
+		return err
+	}
+	pathStrings := plan.paths(make(map[string]struct{}), nil)
+	if len(pathStrings) == 0 {
+		return nil
+	}
+
+	paths, err := ParsePaths(pathStrings...)
+	if err != nil {
+		return err
+	}
+	eval, err := EvalPathsInBytes(data, paths)
+	if err != nil {
+		return err
+	}
+	eval.resultPaths = make(map[*Result]string)
+	results := make(map[string][]*Result)
+	for {
+		r, ok := eval.Next()
+		if !ok {
+			break
+		}
+		path := eval.resultPaths[r]
+		results[path] = append(results[path], r)
+	}
+	if eval.Error != nil {
+		return eval.Error
+	}
+
+	return plan.fill(rv.Elem(), results, nil)
+}
+
+// structPlan holds the tagged fields of a struct type
+type structPlan struct {
+	fields []fieldPlan
+}
+
+type fieldPlan struct {
+	name     string
+	index    int
+	path     string      // absolute path without +
+	relative bool        // path of a nested struct starting with @
+	slice    bool        // one value or struct per match
+	nested   *structPlan // struct (or slice element) with tagged fields
+}
+
+func planStruct(t reflect.Type, parent string) (*structPlan, error) {
+	plan := &structPlan{}
+	for x := 0; x < t.NumField(); x++ {
+		f := t.Field(x)
+		tag, ok := f.Tag.Lookup("jsonpath")
+		if !ok || tag == "-" {
+			continue
+		}
+		if f.PkgPath != "" {
+			return nil, fmt.Errorf("Field %s is unexported", f.Name)
+		}
+
+		path := strings.TrimSuffix(tag, "+")
+		relative := strings.HasPrefix(path, "@")
+		switch {
+		case strings.HasPrefix(path, "$"):
+		case relative && parent != "":
+			path = parent + path[1:]
+		default:
+			return nil, fmt.Errorf("Field %s: expected path starting with $ or, in nested structs, @ instead of %q", f.Name, tag)
+		}
+
+		fp := fieldPlan{name: f.Name, index: x, path: path, relative: relative}
+		elem := f.Type
+		if elem.Kind() == reflect.Slice && elem.Elem().Kind() != reflect.Uint8 {
+			fp.slice = true
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Struct && hasPathTags(elem) {
+			nested, err := planStruct(elem, path)
+			if err != nil {
+				return nil, err
+			}
+			fp.nested = nested
+		}
+		plan.fields = append(plan.fields, fp)
+	}
+	return plan, nil
+}
+
+func hasPathTags(t reflect.Type) bool {
+	for x := 0; x < t.NumField(); x++ {
+		if _, ok := t.Field(x).Tag.Lookup("jsonpath"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// paths lists the path strings to evaluate. Nested structs are located by
+// their path without a value, values by their path with +.
+func (p *structPlan) paths(seen map[string]struct{}, pathStrings []string) []string {
+	for _, f := range p.fields {
+		s := f.path + "+"
+		if f.nested != nil {
+			s = f.path
+			pathStrings = f.nested.paths(seen, pathStrings)
+		}
+		if _, ok := seen[s]; !ok {
+			seen[s] = struct{}{}
+			pathStrings = append(pathStrings, s)
+		}
+	}
+	return pathStrings
+}
+
+// fill sets the fields of v from the results below the location prefix
+func (p *structPlan) fill(v reflect.Value, results map[string][]*Result, prefix []interface{}) error {
+	for _, f := range p.fields {
+		field := v.Field(f.index)
+		s := f.path + "+"
+		if f.nested != nil {
+			s = f.path
+		}
+
+		for _, r := range results[s] {
+			if f.relative && !keysHavePrefix(r.Keys, prefix) {
+				continue
+			}
+
+			target := field
+			if f.slice {
+				target = reflect.New(field.Type().Elem()).Elem()
+			}
+			if f.nested != nil {
+				if err := f.nested.fill(target, results, r.Keys); err != nil {
+					return err
+				}
+			} else if err := r.Decode(target.Addr().Interface()); err != nil {
+				return fmt.Errorf("Field %s: %s", f.name, err)
+			}
+
+			if !f.slice {
+				break
+			}
+			field.Set(reflect.Append(field, target))
+		}
+	}
+	return nil
+}
+
+func keysHavePrefix(keys, prefix []interface{}) bool {
+	if len(keys) < len(prefix) {
+		return false
+	}
+	for x, k := range prefix {
+		switch kv := k.(type) {
+		case int:
+			if i, ok := keys[x].(int); !ok || i != kv {
+				return false
+			}
+		case []byte:
+			if b, ok := keys[x].([]byte); !ok || !byteSlicesEqual(b, kv) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package jsonpath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const orderJSON = `{
+	"id": "A-1",
+	"total": 31.5,
+	"paid": true,
+	"note": null,
+	"tags": ["new", "gift"],
+	"customer": {"name": "Ann", "address": {"city": "Oslo"}},
+	"items": [
+		{"sku": "x", "qty": 1, "opts": ["red"]},
+		{"sku": "y", "qty": 3, "opts": []},
+		{"sku": "z", "qty": 2, "opts": ["a", "b"]}
+	]
+}`
+
+type orderItem struct {
+	SKU  string   `jsonpath:"@.sku"`
+	Qty  int      `jsonpath:"@.qty+"`
+	Opts []string `jsonpath:"@.opts[*]"`
+}
+
+type order struct {
+	ID       string  `jsonpath:"$.id"`
+	Total    float64 `jsonpath:"$.total"`
+	Paid     bool    `jsonpath:"$.paid"`
+	Note     *string `jsonpath:"$.note"`
+	Missing  string  `jsonpath:"$.missing"`
+	Untagged string
+	Tags     []string `jsonpath:"$.tags[*]"`
+	Customer struct {
+		Name string `jsonpath:"@.name"`
+		City string `jsonpath:"@.address.city"`
+		ID   string `jsonpath:"$.id"`
+	} `jsonpath:"$.customer"`
+	Items    []orderItem       `jsonpath:"$.items[*]"`
+	Big      []string          `jsonpath:"$.items[*]?(@.qty > 1).sku"`
+	Address  map[string]string `jsonpath:"$.customer.address"`
+	Quantity []int             `jsonpath:"$..qty"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	as := assert.New(t)
+
+	var o order
+	if !as.NoError(Unmarshal([]byte(orderJSON), &o)) {
+		return
+	}
+	as.Equal("A-1", o.ID)
+	as.Equal(31.5, o.Total)
+	as.True(o.Paid)
+	as.Nil(o.Note)
+	as.Equal("", o.Missing)
+	as.Equal([]string{"new", "gift"}, o.Tags)
+	as.Equal("Ann", o.Customer.Name)
+	as.Equal("Oslo", o.Customer.City)
+	as.Equal("A-1", o.Customer.ID)
+	as.Equal([]orderItem{{"x", 1, []string{"red"}}, {"y", 3, nil}, {"z", 2, []string{"a", "b"}}}, o.Items)
+	as.Equal([]string{"y", "z"}, o.Big)
+	as.Equal(map[string]string{"city": "Oslo"}, o.Address)
+	as.Equal([]int{1, 3, 2}, o.Quantity)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	as := assert.New(t)
+
+	var o order
+	as.Error(Unmarshal([]byte(orderJSON), o))
+	as.Error(Unmarshal([]byte(orderJSON), nil))
+
+	var relative struct {
+		A string `jsonpath:"@.a"`
+	}
+	as.Error(Unmarshal([]byte(`{"a":"b"}`), &relative))
+
+	var mismatch struct {
+		A int `jsonpath:"$.a"`
+	}
+	as.Error(Unmarshal([]byte(`{"a":"b"}`), &mismatch))
+
+	var badPath struct {
+		A int `jsonpath:"$.a[x]"`
+	}
+	as.Error(Unmarshal([]byte(`{"a":1}`), &badPath))
+}